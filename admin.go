@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-openapi/spec"
+	"github.com/gorilla/mux"
+)
+
+type adminOperation struct {
+	OperationID string `json:"operationId,omitempty"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Covered     bool   `json:"covered"`
+	Tally
+}
+
+type adminCoverage struct {
+	Total    int              `json:"total"`
+	Covered  int              `json:"covered"`
+	Pending  []adminOperation `json:"pending"`
+	Coverage float64          `json:"coverage"`
+	Tally    Tally            `json:"tally"`
+}
+
+// AdminHandler returns the admin API, exposing the coverage and the
+// violations found so far:
+//
+//	GET  /operations  every operation with its counters
+//	GET  /coverage    covered and pending operations
+//	GET  /violations  the most recent violations
+//	GET  /metrics     the metrics in the Prometheus text format
+//	POST /reset       resets the counters, violations and pending operations
+func (proxy *Proxy) AdminHandler() http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/operations", proxy.adminOperations).Methods("GET")
+	router.HandleFunc("/coverage", proxy.adminCoverage).Methods("GET")
+	router.HandleFunc("/violations", proxy.adminViolations).Methods("GET")
+	router.Handle("/metrics", proxy.MetricsHandler()).Methods("GET")
+	router.HandleFunc("/reset", proxy.adminReset).Methods("POST")
+	return router
+}
+
+func (proxy *Proxy) operations() []adminOperation {
+	pending := make(map[*spec.Operation]struct{})
+	for _, op := range proxy.PendingOperations() {
+		pending[op] = struct{}{}
+	}
+
+	var ops []adminOperation
+	for _, op := range proxy.current().operations {
+		_, isPending := pending[op.op]
+		ops = append(ops, adminOperation{
+			OperationID: op.op.ID,
+			Method:      op.method,
+			Path:        op.path,
+			Covered:     !isPending,
+			Tally:       proxy.stats.operation(op.method, op.path),
+		})
+	}
+	return ops
+}
+
+func (proxy *Proxy) adminOperations(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, proxy.operations())
+}
+
+func (proxy *Proxy) adminCoverage(w http.ResponseWriter, req *http.Request) {
+	cov := adminCoverage{
+		Pending: []adminOperation{},
+		Tally:   proxy.Tally(),
+	}
+
+	for _, op := range proxy.operations() {
+		cov.Total++
+		if op.Covered {
+			cov.Covered++
+		} else {
+			cov.Pending = append(cov.Pending, op)
+		}
+	}
+
+	if cov.Total > 0 {
+		cov.Coverage = 100 * float64(cov.Covered) / float64(cov.Total)
+	}
+	writeJSON(w, cov)
+}
+
+func (proxy *Proxy) adminViolations(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, proxy.Violations())
+}
+
+func (proxy *Proxy) adminReset(w http.ResponseWriter, req *http.Request) {
+	proxy.Reset()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminHandler(t *testing.T) {
+	app, err := New(openFixture(t, "petstore.json"), &testReporter{})
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(app.Handler(
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("{}"))
+		}),
+	))
+	defer srv.Close()
+
+	admin := httptest.NewServer(app.AdminHandler())
+	defer admin.Close()
+
+	get := func(path string, v interface{}) {
+		resp, err := http.Get(admin.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, 200, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+	}
+
+	http.Get(srv.URL + "/v2/store/inventory")
+	http.Get(srv.URL + "/v2/store/inventory")
+	http.Get(srv.URL + "/v2/pet/findByStatus")
+
+	t.Run("Operations", func(t *testing.T) {
+		var ops []adminOperation
+		get("/operations", &ops)
+
+		byID := make(map[string]adminOperation)
+		for _, op := range ops {
+			byID[op.OperationID] = op
+		}
+		assert.True(t, byID["getInventory"].Covered)
+		assert.Equal(t, 2, byID["getInventory"].Success)
+		assert.Equal(t, 1, byID["findPetsByStatus"].Errors)
+		assert.Equal(t, 1, byID["findPetsByStatus"].RequestErrors)
+		assert.False(t, byID["addPet"].Covered)
+	})
+
+	t.Run("Coverage", func(t *testing.T) {
+		var cov adminCoverage
+		get("/coverage", &cov)
+		assert.Equal(t, 2, cov.Covered)
+		assert.Equal(t, cov.Total-2, len(cov.Pending))
+		assert.Equal(t, 2, cov.Tally.Success)
+	})
+
+	t.Run("Violations", func(t *testing.T) {
+		var records []ViolationRecord
+		get("/violations", &records)
+		require.Len(t, records, 2)
+		assert.True(t, records[0].Request)
+		assert.Equal(t, "findPetsByStatus", records[1].OperationID)
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		resp, err := http.Post(admin.URL+"/reset", "", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		var cov adminCoverage
+		get("/coverage", &cov)
+		assert.Equal(t, 0, cov.Covered)
+		assert.Equal(t, Tally{}, cov.Tally)
+		assert.Empty(t, app.Violations())
+	})
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	proxy "github.com/gchaincl/swagger-proxy"
+)
+
+const envPrefix = "SWAGGER_PROXY_"
+
+// config holds the options of the proxy. They are read, in increasing order
+// of precedence, from the flag defaults, the config file, the SWAGGER_PROXY_*
+// environment variables and the flags given on the command line. Options are
+// named after their flag: -max-body-size is maxBodySize in the config file
+// and SWAGGER_PROXY_MAX_BODY_SIZE in the environment.
+type config struct {
+	Bind    string `json:"bind"`
+	Admin   string `json:"admin"`
+	Spec    string `json:"spec"`
+	Target  string `json:"target"`
+	Verbose bool   `json:"verbose"`
+
+	ReportFormat string `json:"reportFormat"`
+	ReportFile   string `json:"reportFile"`
+	Record       string `json:"record"`
+
+	Enforce     bool   `json:"enforce"`
+	Rules       string `json:"rules"`
+	MaxBodySize int64  `json:"maxBodySize"`
+
+	FailOnError bool    `json:"failOnError"`
+	MinCoverage float64 `json:"minCoverage"`
+	MaxWarnings int64   `json:"maxWarnings"`
+
+	Duration time.Duration `json:"duration"`
+
+	Mock              bool     `json:"mock"`
+	MockOperations    []string `json:"mockOperations"`
+	MockTags          []string `json:"mockTags"`
+	MockUnimplemented bool     `json:"mockUnimplemented"`
+
+	file string
+}
+
+// paths are the options holding a file name, relative file names in the
+// config file are relative to it. The spec and the rules can be URLs too.
+var paths = map[string]bool{"spec": true, "reportFile": true, "record": true, "rules": true}
+
+// reloadable are the options which are applied without restarting.
+var reloadable = map[string]bool{"spec": true, "rules": true}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+var typeNames = map[reflect.Kind]string{
+	reflect.String:  "string",
+	reflect.Bool:    "boolean",
+	reflect.Int64:   "number",
+	reflect.Float64: "number",
+	reflect.Slice:   "list",
+}
+
+// optionName converts a flag name to its option name: max-body-size becomes
+// maxBodySize.
+func optionName(flag string) string {
+	parts := strings.Split(flag, "-")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flag, "-", "_", -1))
+}
+
+// field returns the field of the option name, if any.
+func (c *config) field(name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("json") == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// set sets the option of a flag from its string value.
+func (c *config) set(flag, value string) error {
+	f, ok := c.field(optionName(flag))
+	if !ok {
+		return fmt.Errorf("unknown option %q", flag)
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		f.SetBool(b)
+	case reflect.Int64:
+		if f.Type() == durationType {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q", value)
+			}
+			f.SetInt(int64(d))
+			break
+		}
+
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		f.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		f.SetFloat(n)
+	case reflect.Slice:
+		f.Set(reflect.ValueOf(splitList(value)))
+	}
+	return nil
+}
+
+// load sets the options found in a YAML or JSON file.
+func (c *config) load(file string) error {
+	data, err := proxy.LoadJSONDocument(file)
+	if err != nil {
+		return err
+	}
+
+	var options map[string]json.RawMessage
+	if err := json.Unmarshal(data, &options); err != nil {
+		return fmt.Errorf("%s: %s", file, err)
+	}
+
+	for name, raw := range options {
+		f, ok := c.field(name)
+		if !ok {
+			return fmt.Errorf("%s: unknown option %q", file, name)
+		}
+
+		// Lists can also be comma separated, and durations are strings
+		var s string
+		if f.Kind() == reflect.Slice && json.Unmarshal(raw, &s) == nil {
+			f.Set(reflect.ValueOf(splitList(s)))
+			continue
+		}
+		if f.Type() == durationType {
+			json.Unmarshal(raw, &s)
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("%s: %s should be a duration such as 5m", file, name)
+			}
+			f.SetInt(int64(d))
+			continue
+		}
+
+		if err := json.Unmarshal(raw, f.Addr().Interface()); err != nil {
+			return fmt.Errorf("%s: %s should be a %s", file, name, typeNames[f.Kind()])
+		}
+
+		if paths[name] && f.String() != "" && !filepath.IsAbs(f.String()) && !isURL(f.String()) {
+			f.SetString(filepath.Join(filepath.Dir(file), f.String()))
+		}
+	}
+
+	c.file = file
+	return nil
+}
+
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+func (c *config) validate() error {
+	if c.Bind == "" {
+		return fmt.Errorf("bind is required")
+	}
+
+	if c.Spec == "" {
+		return fmt.Errorf("spec is required")
+	}
+
+	if u, err := url.Parse(c.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("target %q should be an http or https URL", c.Target)
+	}
+
+	switch c.ReportFormat {
+	case "text", "json", "junit":
+	default:
+		return fmt.Errorf("reportFormat %q should be text, json or junit", c.ReportFormat)
+	}
+
+	if c.ReportFile != "" && c.ReportFormat == "text" {
+		return fmt.Errorf("reportFile requires the json or junit reportFormat")
+	}
+
+	if c.MaxBodySize < 0 {
+		return fmt.Errorf("maxBodySize should be positive, or 0 for no limit")
+	}
+
+	if c.MinCoverage < 0 || c.MinCoverage > 100 {
+		return fmt.Errorf("minCoverage should be a percentage between 0 and 100")
+	}
+
+	if c.MaxWarnings < -1 {
+		return fmt.Errorf("maxWarnings should be positive, or -1 for no limit")
+	}
+
+	if c.Duration < 0 {
+		return fmt.Errorf("duration should be positive, or 0 to run until interrupted")
+	}
+	return nil
+}
+
+// changed returns the options which differ from old.
+func (c *config) changed(old *config) []string {
+	var names []string
+	v, o := reflect.ValueOf(c).Elem(), reflect.ValueOf(old).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Tag.Get("json")
+		if name != "" && !reflect.DeepEqual(v.Field(i).Interface(), o.Field(i).Interface()) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// loadConfig builds the config from the flags, the config file and the
+// environment. The file defaults to $SWAGGER_PROXY_CONFIG.
+func loadConfig(flags *flag.FlagSet, file string) (*config, error) {
+	c := &config{}
+
+	var err error
+	set := func(f *flag.Flag, value, source string) {
+		if err != nil || f.Name == "config" {
+			return
+		}
+		if e := c.set(f.Name, value); e != nil {
+			err = fmt.Errorf("%s: %s", source, e)
+		}
+	}
+
+	flags.VisitAll(func(f *flag.Flag) { set(f, f.DefValue, "-"+f.Name) })
+	if err != nil {
+		return nil, err
+	}
+
+	if file == "" {
+		file = os.Getenv(envName("config"))
+	}
+	if file != "" {
+		if err := c.load(file); err != nil {
+			return nil, err
+		}
+	}
+
+	flags.VisitAll(func(f *flag.Flag) {
+		if value, ok := os.LookupEnv(envName(f.Name)); ok {
+			set(f, value, envName(f.Name))
+		}
+	})
+	flags.Visit(func(f *flag.Flag) { set(f, f.Value.String(), "-"+f.Name) })
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("Invalid config: %s", err)
+	}
+	return c, nil
+}
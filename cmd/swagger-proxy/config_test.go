@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFlags(args ...string) *flag.FlagSet {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.String("config", "", "")
+	flags.String("bind", ":1234", "")
+	flags.String("spec", "swagger.yml", "")
+	flags.String("target", "http://localhost:4321", "")
+	flags.Bool("verbose", false, "")
+	flags.String("report-format", "text", "")
+	flags.String("mock-tags", "", "")
+	flags.Int64("max-body-size", 10, "")
+	flags.Duration("duration", 0, "")
+	flags.Parse(args)
+	return flags
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	file := filepath.Join(t.TempDir(), name)
+	require.NoError(t, ioutil.WriteFile(file, []byte(content), 0644))
+	return file
+}
+
+func TestLoadConfig(t *testing.T) {
+	file := writeConfig(t, "config.yml", `
+spec: specs/petstore.yml
+target: http://localhost:8080
+reportFormat: json
+mockTags: [pet, store]
+maxBodySize: 1024
+duration: 5m
+`)
+
+	t.Run("Defaults", func(t *testing.T) {
+		cfg, err := loadConfig(testFlags(), "")
+		require.NoError(t, err)
+		assert.Equal(t, ":1234", cfg.Bind)
+		assert.Equal(t, "swagger.yml", cfg.Spec)
+		assert.Equal(t, int64(10), cfg.MaxBodySize)
+		assert.Empty(t, cfg.MockTags)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		cfg, err := loadConfig(testFlags(), file)
+		require.NoError(t, err)
+		assert.Equal(t, ":1234", cfg.Bind)
+		assert.Equal(t, filepath.Join(filepath.Dir(file), "specs/petstore.yml"), cfg.Spec)
+		assert.Equal(t, "http://localhost:8080", cfg.Target)
+		assert.Equal(t, "json", cfg.ReportFormat)
+		assert.Equal(t, []string{"pet", "store"}, cfg.MockTags)
+		assert.Equal(t, int64(1024), cfg.MaxBodySize)
+		assert.Equal(t, 5*time.Minute, cfg.Duration)
+	})
+
+	t.Run("URLs", func(t *testing.T) {
+		file := writeConfig(t, "config.yml", `
+spec: https://petstore.swagger.io/v2/swagger.json
+rules: rules.yml
+`)
+		cfg, err := loadConfig(testFlags(), file)
+		require.NoError(t, err)
+		assert.Equal(t, "https://petstore.swagger.io/v2/swagger.json", cfg.Spec)
+		assert.Equal(t, filepath.Join(filepath.Dir(file), "rules.yml"), cfg.Rules)
+	})
+
+	t.Run("Overrides", func(t *testing.T) {
+		t.Setenv("SWAGGER_PROXY_CONFIG", file)
+		t.Setenv("SWAGGER_PROXY_TARGET", "http://target:80")
+		t.Setenv("SWAGGER_PROXY_MAX_BODY_SIZE", "2048")
+		t.Setenv("SWAGGER_PROXY_MOCK_TAGS", "user")
+		t.Setenv("SWAGGER_PROXY_DURATION", "90s")
+
+		cfg, err := loadConfig(testFlags("-max-body-size", "0", "-verbose"), "")
+		require.NoError(t, err)
+		assert.Equal(t, "http://target:80", cfg.Target)
+		assert.Equal(t, int64(0), cfg.MaxBodySize)
+		assert.Equal(t, []string{"user"}, cfg.MockTags)
+		assert.True(t, cfg.Verbose)
+		assert.Equal(t, 90*time.Second, cfg.Duration)
+		assert.Equal(t, "json", cfg.ReportFormat)
+	})
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for _, test := range []struct {
+		name, config, env, err string
+	}{
+		{"Unknown", `{"targets": "http://localhost"}`, "", `unknown option "targets"`},
+		{"Type", `{"maxBodySize": "big"}`, "", "maxBodySize should be a number"},
+		{"Target", `{"target": "localhost:8080"}`, "", `target "localhost:8080" should be an http or https URL`},
+		{"Format", `{"reportFormat": "xml"}`, "", `reportFormat "xml" should be text, json or junit`},
+		{"Duration", `{"duration": 60}`, "", "duration should be a duration such as 5m"},
+		{"Coverage", `{"minCoverage": 120}`, "", "minCoverage should be a percentage between 0 and 100"},
+		{"Env", `{}`, "yes", `SWAGGER_PROXY_VERBOSE: invalid boolean "yes"`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if test.env != "" {
+				t.Setenv("SWAGGER_PROXY_VERBOSE", test.env)
+			}
+
+			_, err := loadConfig(testFlags(), writeConfig(t, "config.json", test.config))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+
+	_, err := loadConfig(testFlags(), filepath.Join(os.TempDir(), "missing.yml"))
+	assert.Error(t, err)
+}
+
+func TestConfigChanged(t *testing.T) {
+	old := &config{Spec: "a.yml", Rules: "rules.yml", MockTags: []string{"pet"}}
+	cfg := &config{Spec: "b.yml", Rules: "rules.yml", MockTags: []string{"pet", "store"}, file: "config.yml"}
+	assert.Equal(t, []string{"spec", "mockTags"}, cfg.changed(old))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	proxy "github.com/gchaincl/swagger-proxy"
+)
+
+// gate holds the conditions a run has to meet for the command to exit 0.
+type gate struct {
+	failOnError bool
+	minCoverage float64
+	maxWarnings int64 // -1 means no limit
+}
+
+// check returns the conditions the run didn't meet.
+func (g gate) check(tally proxy.Tally, covered, total int) []string {
+	var failures []string
+	if errors := tally.Errors + tally.RequestErrors; g.failOnError && errors > 0 {
+		failures = append(failures, fmt.Sprintf("%d exchanges didn't conform to the spec", errors))
+	}
+
+	if coverage := percent(covered, total); coverage < g.minCoverage {
+		failures = append(failures, fmt.Sprintf("coverage %.1f%% is below %.1f%%", coverage, g.minCoverage))
+	}
+
+	if g.maxWarnings >= 0 && int64(tally.Warnings) > g.maxWarnings {
+		failures = append(failures, fmt.Sprintf("%d warnings, more than %d", tally.Warnings, g.maxWarnings))
+	}
+	return failures
+}
+
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(covered) / float64(total)
+}
+
+// printSummary prints the outcomes and the coverage of the run, followed by
+// the conditions it didn't meet. It returns the exit code of the command.
+func printSummary(w io.Writer, px *proxy.Proxy, g gate) int {
+	tally := px.Tally()
+	covered, total := px.Coverage()
+
+	fmt.Fprintln(w, "Summary:")
+	fmt.Fprintln(w, "--------")
+	fmt.Fprintf(w, "Exchanges: %d success, %d errors, %d request errors, %d warnings, %d suppressed\n",
+		tally.Success, tally.Errors, tally.RequestErrors, tally.Warnings, tally.Suppressed)
+	fmt.Fprintf(w, "Coverage: %d/%d operations (%.1f%%)\n", covered, total, percent(covered, total))
+
+	failures := g.check(tally, covered, total)
+	for _, f := range failures {
+		fmt.Fprintln(w, "FAIL:", f)
+	}
+
+	if len(failures) > 0 {
+		return 1
+	}
+	return 0
+}
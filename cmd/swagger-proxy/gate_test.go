@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	proxy "github.com/gchaincl/swagger-proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGate(t *testing.T) {
+	tally := proxy.Tally{Success: 10, RequestErrors: 1, Warnings: 3}
+
+	assert.Empty(t, gate{maxWarnings: -1}.check(tally, 1, 4))
+	assert.Empty(t, gate{minCoverage: 25, maxWarnings: 3}.check(tally, 1, 4))
+	assert.Empty(t, gate{minCoverage: 100, maxWarnings: -1}.check(proxy.Tally{}, 0, 0))
+
+	assert.Equal(t, []string{
+		"1 exchanges didn't conform to the spec",
+		"coverage 25.0% is below 80.0%",
+		"3 warnings, more than 0",
+	}, gate{failOnError: true, minCoverage: 80, maxWarnings: 0}.check(tally, 1, 4))
+}
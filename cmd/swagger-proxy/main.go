@@ -1,44 +1,72 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	proxy "github.com/gchaincl/swagger-proxy"
-	"github.com/go-openapi/loads"
 )
 
 const version = "v0.0.1"
 
-func serve(proxy *proxy.Proxy, bind string) error {
+// shutdownTimeout bounds the time given to the requests in flight to
+// complete once the proxy is stopping.
+const shutdownTimeout = 30 * time.Second
+
+// serve serves until it gets an interrupt or a SIGTERM, or until duration if
+// not 0. It then waits for the requests in flight to complete, so they make
+// it into the report. A second signal stops it right away.
+func serve(proxy *proxy.Proxy, l net.Listener, duration time.Duration) error {
 	s := http.Server{
-		Addr:    bind,
 		Handler: proxy.Router(),
 	}
 
-	errC := make(chan error)
+	errC := make(chan error, 1)
 	go func() {
-		log.Println("SwaggerProxy", version, "listening on", bind, "->", proxy.Target())
-		errC <- s.ListenAndServe()
+		log.Println("SwaggerProxy", version, "listening on", l.Addr(), "->", proxy.Target())
+		errC <- s.Serve(l)
 	}()
 
 	sigC := make(chan os.Signal, 1)
-	signal.Notify(sigC, os.Interrupt)
+	signal.Notify(sigC, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigC)
+
+	var timeout <-chan time.Time
+	if duration > 0 {
+		timeout = time.After(duration)
+	}
 
 	select {
 	case err := <-errC:
 		return err
 	case s := <-sigC:
 		log.Printf("%s", s)
-		return nil
+	case <-timeout:
+		log.Printf("Stopping after %s", duration)
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-sigC:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return s.Shutdown(ctx)
 }
 
 func sameFiles(a, b string) bool {
@@ -55,44 +83,92 @@ func sameFiles(a, b string) bool {
 	return absA == absB
 }
 
-func reload(proxy *proxy.Proxy, spec string) error {
-	doc, err := loads.Spec(spec)
+func reload(px *proxy.Proxy, spec string) error {
+	doc, err := proxy.LoadSpec(spec)
 	if err != nil {
 		return err
 	}
 
-	if err := proxy.SetSpec(doc.Spec()); err != nil {
+	if err := px.SetSpec(doc); err != nil {
 		return err
 	}
 	return nil
 }
 
-func watchFor(px *proxy.Proxy, spec string) error {
+// reloadConfig loads the config again and applies the rules, the options
+// which need a restart are logged.
+func reloadConfig(px *proxy.Proxy, old *config, load func() (*config, error)) (*config, error) {
+	cfg, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := loadRules(cfg.Rules)
+	if err != nil {
+		return nil, err
+	}
+	px.SetRules(rules)
+
+	for _, name := range cfg.changed(old) {
+		if !reloadable[name] {
+			log.Printf("%s changed, restart to apply it", name)
+		}
+	}
+	return cfg, nil
+}
+
+// watchFor reloads the spec when it changes, and the config file, if any,
+// along with it.
+func watchFor(px *proxy.Proxy, cfg *config, load func() (*config, error)) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return err
 	}
 
-	if err := watcher.Add(filepath.Dir(spec)); err != nil {
+	if err := watcher.Add(filepath.Dir(cfg.Spec)); err != nil {
 		return err
 	}
+	if cfg.file != "" {
+		if err := watcher.Add(filepath.Dir(cfg.file)); err != nil {
+			return err
+		}
+	}
 	defer watcher.Close()
 
-	needReload := false
+	needReload, needConfig := false, false
 	for {
 		select {
 		case <-time.After(100 * time.Millisecond):
+			if needConfig {
+				needConfig = false
+				log.Println("Reloading", cfg.file)
+				newCfg, err := reloadConfig(px, cfg, load)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+				if newCfg.Spec != cfg.Spec {
+					if err := watcher.Add(filepath.Dir(newCfg.Spec)); err != nil {
+						log.Println(err)
+					}
+					needReload = true
+				}
+				cfg = newCfg
+			}
+
 			if needReload {
 				needReload = false
-				log.Println("Reloading", spec)
-				if err := reload(px, spec); err != nil {
+				log.Println("Reloading", cfg.Spec)
+				if err := reload(px, cfg.Spec); err != nil {
 					log.Println(err)
 					continue
 				}
 				needReload = false
 			}
 		case ev := <-watcher.Events:
-			if !sameFiles(ev.Name, spec) {
+			spec := sameFiles(ev.Name, cfg.Spec)
+			conf := cfg.file != "" && sameFiles(ev.Name, cfg.file)
+			if !spec && !conf {
 				continue
 			}
 
@@ -100,43 +176,289 @@ func watchFor(px *proxy.Proxy, spec string) error {
 				continue
 			}
 
-			needReload = true
+			needReload = needReload || spec
+			needConfig = needConfig || conf
 		case <-watcher.Errors:
 			// TODO: handle this
 		}
 	}
 }
 
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func newReporter(format, file string) (proxy.Reporter, error) {
+	switch format {
+	case "text":
+		return &proxy.LogReporter{}, nil
+	case "json", "junit":
+		out := os.Stdout
+		if file != "" {
+			f, err := os.Create(file)
+			if err != nil {
+				return nil, err
+			}
+			out = f
+		}
+
+		if format == "json" {
+			return proxy.NewJSONReporter(out), nil
+		}
+		return proxy.NewJUnitReporter(out), nil
+	}
+	return nil, fmt.Errorf("Unknown report format %q", format)
+}
+
+// textOutput returns where the human readable output goes: stderr when the
+// json or junit report is written to stdout, so it can be parsed.
+func textOutput(format, file string) io.Writer {
+	if format != "text" && file == "" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+func loadRules(file string) (*proxy.Rules, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	rules, err := proxy.LoadRules(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules.Expired() {
+		log.Printf("Rule for %s %s expired on %s", r.OperationID, r.Path, r.Expires)
+	}
+	return rules, nil
+}
+
+func printRules(w io.Writer, rules *proxy.Rules) {
+	if rules == nil {
+		return
+	}
+
+	fmt.Fprintln(w, "Rules:")
+	fmt.Fprintln(w, "------")
+	for i, r := range rules.Summary() {
+		fmt.Fprintf(w, "%03d) %s %d violations", i+1, r.Action, r.Matched)
+		if r.OperationID != "" {
+			fmt.Fprintf(w, " id=%s", r.OperationID)
+		}
+		if r.Path != "" {
+			fmt.Fprintf(w, " path=%s", r.Path)
+		}
+		if r.Reason != "" {
+			fmt.Fprintf(w, " (%s)", r.Reason)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func printPending(w io.Writer, px *proxy.Proxy) {
+	fmt.Fprintln(w, "Pending Operations:")
+	fmt.Fprintln(w, "------------------")
+	for i, op := range px.PendingOperations() {
+		fmt.Fprintf(w, "%03d) id=%s\n", i+1, op.ID)
+	}
+}
+
+// validateHAR implements `swagger-proxy validate-har -spec swagger.yml traffic.har`.
+func validateHAR(args []string) error {
+	flags := flag.NewFlagSet("validate-har", flag.ExitOnError)
+	specFile := flags.String("spec", "swagger.yml", "Swagger Spec")
+	reportFormat := flags.String("report-format", "text", "Report format (text, json or junit)")
+	reportFile := flags.String("report-file", "", "Write the json or junit report to this file instead of stdout")
+	rulesFile := flags.String("rules", "", "Rules suppressing or downgrading known violations")
+	minCoverage := flags.Float64("min-coverage", 0, "Exit with status 1 if less than this percentage of operations was exercised")
+	maxWarnings := flags.Int64("max-warnings", -1, "Exit with status 1 if there are more warnings than this, -1 means no limit")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: swagger-proxy validate-har [flags] traffic.har...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	reporter, err := newReporter(*reportFormat, *reportFile)
+	if err != nil {
+		return err
+	}
+	junit, _ := reporter.(*proxy.JUnitReporter)
+
+	doc, err := proxy.LoadSpec(*specFile)
+	if err != nil {
+		return err
+	}
+
+	rules, err := loadRules(*rulesFile)
+	if err != nil {
+		return err
+	}
+
+	px, err := proxy.New(doc, reporter, proxy.WithRules(rules))
+	if err != nil {
+		return err
+	}
+	if junit != nil {
+		junit.Pending = px.PendingOperations
+	}
+
+	for _, file := range flags.Args() {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		err = px.ValidateHAR(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+	}
+	reporter.Report()
+
+	out := textOutput(*reportFormat, *reportFile)
+	printRules(out, rules)
+	printPending(out, px)
+
+	g := gate{failOnError: true, minCoverage: *minCoverage, maxWarnings: *maxWarnings}
+	if code := printSummary(out, px, g); code != 0 {
+		os.Exit(code)
+	}
+	return nil
+}
+
 func main() {
-	bind := flag.String("bind", ":1234", "Bind Address")
-	spec := flag.String("spec", "swagger.yml", "Swagger Spec")
-	target := flag.String("target", "http://localhost:4321", "Target")
-	verbose := flag.Bool("verbose", false, "Verbose")
+	if len(os.Args) > 1 && os.Args[1] == "validate-har" {
+		if err := validateHAR(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	configFile := flag.String("config", "", "YAML or JSON config file, its options are overridden by SWAGGER_PROXY_* variables and flags")
+	flag.String("bind", ":1234", "Bind Address")
+	flag.String("admin", "", "Admin API Bind Address (disabled when empty)")
+	flag.String("spec", "swagger.yml", "Swagger Spec")
+	flag.String("target", "http://localhost:4321", "Target")
+	flag.Bool("verbose", false, "Verbose")
+	flag.Bool("enforce", false, "Replace non-conforming responses with a 502 problem+json")
+	flag.Bool("mock", false, "Answer with responses generated from the spec instead of proxying to the target")
+	flag.String("mock-operations", "", "Comma separated operationIds to mock")
+	flag.String("mock-tags", "", "Comma separated tags whose operations are mocked")
+	flag.Bool("mock-unimplemented", false, "Mock the operations the target answers with 501 or an undefined 404")
+	flag.String("report-format", "text", "Report format (text, json or junit)")
+	flag.String("report-file", "", "Write the json or junit report to this file instead of stdout")
+	flag.String("record", "", "Record the exchanges to this HAR file")
+	flag.String("rules", "", "Rules suppressing or downgrading known violations")
+	flag.Int64("max-body-size", proxy.DefaultMaxBodySize, "Maximum size in bytes of the request and response bodies validated, 0 means no limit")
+	flag.Bool("fail-on-error", false, "Exit with status 1 if any exchange didn't conform to the spec")
+	flag.Float64("min-coverage", 0, "Exit with status 1 if less than this percentage of operations was exercised")
+	flag.Int64("max-warnings", -1, "Exit with status 1 if there are more warnings than this, -1 means no limit")
+	flag.Duration("duration", 0, "Stop after this duration, 0 means running until interrupted")
 	flag.Parse()
 
-	doc, err := loads.Spec(*spec)
+	load := func() (*config, error) { return loadConfig(flag.CommandLine, *configFile) }
+	cfg, err := load()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	proxy, err := proxy.New(doc.Spec(), &proxy.LogReporter{},
-		proxy.WithTarget(*target),
-		proxy.WithVerbose(*verbose),
-	)
+	reporter, err := newReporter(cfg.ReportFormat, cfg.ReportFile)
 	if err != nil {
 		log.Fatal(err)
 	}
+	junit, _ := reporter.(*proxy.JUnitReporter)
 
-	go watchFor(proxy, *spec)
+	doc, err := proxy.LoadSpec(cfg.Spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rules, err := loadRules(cfg.Rules)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out := textOutput(cfg.ReportFormat, cfg.ReportFile)
+	opts := []proxy.ProxyOpt{
+		proxy.WithTarget(cfg.Target),
+		proxy.WithVerbose(cfg.Verbose),
+		proxy.WithOutput(out),
+		proxy.WithEnforce(cfg.Enforce),
+		proxy.WithMock(cfg.Mock),
+		proxy.WithMockOperations(cfg.MockOperations...),
+		proxy.WithMockTags(cfg.MockTags...),
+		proxy.WithMockUnimplemented(cfg.MockUnimplemented),
+		proxy.WithMaxBodySize(cfg.MaxBodySize),
+		proxy.WithRules(rules),
+	}
+
+	var recorder *proxy.HARRecorder
+	if cfg.Record != "" {
+		f, err := os.Create(cfg.Record)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+
+		recorder = proxy.NewHARRecorder(f)
+		opts = append(opts, proxy.WithHARRecorder(recorder))
+	}
+
+	proxy, err := proxy.New(doc, reporter, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	if err := serve(proxy, *bind); err != nil {
+	if junit != nil {
+		junit.Pending = proxy.PendingOperations
+	}
+
+	go watchFor(proxy, cfg, load)
+
+	if cfg.Admin != "" {
+		go func() {
+			log.Println("Admin API listening on", cfg.Admin)
+			if err := http.ListenAndServe(cfg.Admin, proxy.AdminHandler()); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+
+	l, err := net.Listen("tcp", cfg.Bind)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := serve(proxy, l, cfg.Duration); err != nil {
 		log.Println(err)
 	}
+	reporter.Report()
+
+	if recorder != nil {
+		if err := recorder.Close(); err != nil {
+			log.Println(err)
+		}
+	}
+
+	printRules(out, proxy.Rules())
 
 	// Report PendingOperations
-	fmt.Println("Pending Operations:")
-	fmt.Println("------------------")
-	for i, op := range proxy.PendingOperations() {
-		fmt.Printf("%03d) id=%s\n", i+1, op.ID)
+	printPending(out, proxy)
+
+	g := gate{failOnError: cfg.FailOnError, minCoverage: cfg.MinCoverage, maxWarnings: cfg.MaxWarnings}
+	if code := printSummary(out, proxy, g); code != 0 {
+		os.Exit(code)
 	}
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	proxy "github.com/gchaincl/swagger-proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopReporter struct{}
+
+func (nopReporter) Success(*http.Request)         {}
+func (nopReporter) Error(*http.Request, error)    {}
+func (nopReporter) Warning(*http.Request, string) {}
+func (nopReporter) Report()                       {}
+
+func TestServeDrainsRequests(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"sold": 1}`))
+	}))
+	defer target.Close()
+
+	doc, err := proxy.LoadSpec("../../fixtures/petstore.json")
+	require.NoError(t, err)
+	px, err := proxy.New(doc, nopReporter{}, proxy.WithTarget(target.URL))
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() { done <- serve(px, l, 50*time.Millisecond) }()
+
+	resp, err := http.Get("http://" + l.Addr().String() + "/v2/store/inventory")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, <-done)
+	assert.Equal(t, 1, px.Tally().Success)
+
+	_, err = http.Get("http://" + l.Addr().String() + "/v2/store/inventory")
+	assert.Error(t, err, "the proxy is stopped")
+}
+
+func TestTextOutput(t *testing.T) {
+	assert.Equal(t, os.Stdout, textOutput("text", ""))
+	assert.Equal(t, os.Stdout, textOutput("json", "report.json"))
+	assert.Equal(t, os.Stderr, textOutput("json", ""))
+	assert.Equal(t, os.Stderr, textOutput("junit", ""))
+}
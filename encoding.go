@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// contentCodings returns the codings of a Content-Encoding or
+// Accept-Encoding header, lowercased.
+func contentCodings(header http.Header, key string) []string {
+	var codings []string
+	for _, value := range header[key] {
+		for _, coding := range strings.Split(value, ",") {
+			if coding = strings.ToLower(strings.TrimSpace(coding)); coding != "" {
+				codings = append(codings, coding)
+			}
+		}
+	}
+	return codings
+}
+
+// notValidated is the error of a body which can't be validated. It isn't a
+// violation, Handler reports it as a warning.
+type notValidated string
+
+func (e notValidated) Error() string { return string(e) }
+
+const errBodyTooLarge = notValidated("Body too large, not validated")
+
+// decodeBody returns the body of resp decoded according to its
+// Content-Encoding, up to limit bytes (0 means no limit). The recorded body
+// is left untouched.
+func decodeBody(resp Response, limit int64) ([]byte, error) {
+	body := resp.Body()
+	if len(body) == 0 {
+		return body, nil
+	}
+
+	// Codings are listed in the order they were applied
+	codings := contentCodings(resp.Header(), "Content-Encoding")
+	for i := len(codings) - 1; i >= 0; i-- {
+		var r io.Reader
+		var err error
+		switch codings[i] {
+		case "identity":
+			continue
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(bytes.NewReader(body))
+		case "deflate":
+			// deflate is meant to be zlib wrapped, but raw deflate is common
+			r, err = zlib.NewReader(bytes.NewReader(body))
+			if err != nil {
+				r, err = flate.NewReader(bytes.NewReader(body)), nil
+			}
+		case "br":
+			r = brotli.NewReader(bytes.NewReader(body))
+		default:
+			// Such as compress or zstd, which there is no decoder for
+			return nil, notValidated(fmt.Sprintf("Content-Encoding %q can't be decoded, not validated", codings[i]))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Content-Encoding %q: %s", codings[i], err)
+		}
+
+		if limit > 0 {
+			r = io.LimitReader(r, limit+1)
+		}
+		if body, err = ioutil.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("Content-Encoding %q: %s", codings[i], err)
+		}
+		if limit > 0 && int64(len(body)) > limit {
+			return nil, errBodyTooLarge
+		}
+	}
+	return body, nil
+}
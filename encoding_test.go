@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compress(t *testing.T, coding string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "raw-deflate":
+		var err error
+		w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+		require.NoError(t, err)
+	}
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	body := []byte(`{"sold": 1}`)
+
+	for _, test := range []struct {
+		encoding string
+		body     []byte
+	}{
+		{"", body},
+		{"identity", body},
+		{"gzip", compress(t, "gzip", body)},
+		{"deflate", compress(t, "deflate", body)},
+		{"deflate", compress(t, "raw-deflate", body)},
+		{"br", compress(t, "br", body)},
+		{"deflate, gzip", compress(t, "gzip", compress(t, "deflate", body))},
+		{"gzip, br", compress(t, "br", compress(t, "gzip", body))},
+	} {
+		resp := &testResponse{header: http.Header{}, body: test.body}
+		resp.Header().Set("Content-Encoding", test.encoding)
+
+		decoded, err := decodeBody(resp, 0)
+		require.NoError(t, err, test.encoding)
+		assert.Equal(t, body, decoded, test.encoding)
+	}
+
+	resp := &testResponse{header: http.Header{}, body: []byte("...")}
+	resp.Header().Set("Content-Encoding", "zstd")
+	_, err := decodeBody(resp, 0)
+	assert.IsType(t, notValidated(""), err)
+
+	// Bodies are only decoded up to the limit
+	resp = &testResponse{header: http.Header{}, body: compress(t, "gzip", make([]byte, 1<<20))}
+	resp.Header().Set("Content-Encoding", "gzip")
+	_, err = decodeBody(resp, 1<<10)
+	assert.Equal(t, errBodyTooLarge, err)
+	decoded, err := decodeBody(resp, 1<<20)
+	require.NoError(t, err)
+	assert.Len(t, decoded, 1<<20)
+}
+
+func TestCompressedResponses(t *testing.T) {
+	var encoding string
+	var body []byte
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(body)
+	}))
+	defer target.Close()
+
+	reporter := &testReporter{}
+	app, err := New(openFixture(t, "petstore.json"), reporter, WithTarget(target.URL), WithMaxBodySize(1<<10))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(app.Router())
+	defer srv.Close()
+
+	get := func() []byte {
+		req, err := http.NewRequest("GET", srv.URL+"/v2/store/inventory", nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, encoding, resp.Header.Get("Content-Encoding"))
+
+		received, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return received
+	}
+
+	encoding, body = "gzip", compress(t, "gzip", []byte(`{"sold": 1}`))
+	assert.Equal(t, body, get(), "the client gets the original bytes")
+	assert.Len(t, reporter.success, 1)
+
+	encoding, body = "br", compress(t, "br", []byte(`{"sold": 1}`))
+	assert.Equal(t, body, get())
+	assert.Len(t, reporter.success, 2)
+
+	// zstd can't be decoded
+	encoding, body = "zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}
+	get()
+	assert.Equal(t, []string{`Content-Encoding "zstd" can't be decoded, not validated`}, reporter.warnings)
+
+	// Over the limit once decoded
+	encoding, body = "gzip", compress(t, "gzip", append([]byte(`{"sold": 1, "x": "`), bytes.Repeat([]byte("x"), 1<<20)...))
+	get()
+	assert.Equal(t, "Body too large, not validated", reporter.warnings[1])
+	assert.Empty(t, reporter.errors)
+}
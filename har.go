@@ -0,0 +1,361 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// The HAR 1.2 format, as described in
+// http://www.softwareishard.com/blog/har-12-spec/. Only the fields used by
+// the Proxy are declared.
+type har struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+
+	Validation *harValidation `json:"_validation,omitempty"`
+}
+
+// harValidation is the outcome of validating the entry against the spec.
+type harValidation struct {
+	Outcome       string      `json:"outcome"`
+	OperationID   string      `json:"operationId,omitempty"`
+	Errors        []Violation `json:"errors,omitempty"`
+	RequestErrors []Violation `json:"requestErrors,omitempty"`
+	Warning       string      `json:"warning,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func (r *harRequest) httpRequest() (*http.Request, error) {
+	var body io.Reader
+	if r.PostData != nil {
+		body = strings.NewReader(r.PostData.Text)
+	}
+
+	req, err := http.NewRequest(r.Method, r.URL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range r.Headers {
+		// HTTP/2 pseudo headers (:method, :path, ...)
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		req.Header.Add(h.Name, h.Value)
+	}
+	if r.PostData != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", r.PostData.MimeType)
+	}
+	return req, nil
+}
+
+// ServeHTTP answers with the recorded response.
+func (r *harResponse) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	for _, h := range r.Headers {
+		// The content is stored decoded
+		switch http.CanonicalHeaderKey(h.Name) {
+		case "Content-Encoding", "Content-Length":
+			continue
+		}
+		w.Header().Add(h.Name, h.Value)
+	}
+
+	body := []byte(r.Content.Text)
+	if r.Content.Encoding == "base64" {
+		if data, err := base64.StdEncoding.DecodeString(r.Content.Text); err == nil {
+			body = data
+		}
+	}
+
+	w.WriteHeader(r.Status)
+	w.Write(body)
+}
+
+// discardWriter is a ResponseWriter going nowhere.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) WriteHeader(int)             {}
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+// ValidateHAR validates the exchanges recorded in a HAR file, as if they had
+// gone through the Proxy. Nothing is sent over the network.
+func (proxy *Proxy) ValidateHAR(r io.Reader) error {
+	var doc har
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return err
+	}
+
+	for i := range doc.Log.Entries {
+		entry := &doc.Log.Entries[i]
+		// Aborted requests have no response
+		if entry.Response.Status == 0 {
+			continue
+		}
+
+		req, err := entry.Request.httpRequest()
+		if err != nil {
+			return err
+		}
+
+		w := &discardWriter{header: make(http.Header)}
+		proxy.Handler(&entry.Response).ServeHTTP(w, req)
+	}
+	return nil
+}
+
+const harPrologue = `{"log":{"version":"1.2","creator":{"name":"swagger-proxy","version":""},"entries":[` + "\n"
+
+// HARRecorder writes the exchanges going through the Proxy as a HAR 1.2
+// document. Entries are written as they are recorded, Close terminates the
+// document.
+type HARRecorder struct {
+	mu      sync.Mutex
+	w       io.Writer
+	entries int
+	closed  bool
+	err     error
+}
+
+func NewHARRecorder(w io.Writer) *HARRecorder {
+	return &HARRecorder{w: w}
+}
+
+func (r *HARRecorder) record(entry *harEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed || r.err != nil {
+		return
+	}
+
+	sep := ",\n"
+	if r.entries == 0 {
+		sep = harPrologue
+	}
+	if _, r.err = io.WriteString(r.w, sep); r.err == nil {
+		_, r.err = r.w.Write(data)
+	}
+	r.entries++
+}
+
+// Close terminates the document. Nothing is recorded afterwards.
+func (r *HARRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed || r.err != nil {
+		return r.err
+	}
+	r.closed = true
+
+	if r.entries == 0 {
+		if _, r.err = io.WriteString(r.w, harPrologue); r.err != nil {
+			return r.err
+		}
+	}
+	_, r.err = io.WriteString(r.w, "\n]}}\n")
+	return r.err
+}
+
+func (proxy *Proxy) record(req *http.Request, v *harValidation) {
+	if proxy.har == nil {
+		return
+	}
+
+	ex := ExchangeOf(req)
+	if ex == nil || ex.response == nil {
+		return
+	}
+	if ex.Operation != nil {
+		v.OperationID = ex.Operation.ID
+	}
+	if ex.RequestErr != nil {
+		v.RequestErrors = Violations(ex.RequestErr)
+	}
+
+	proxy.har.record(newHAREntry(req, ex, v, proxy.maxBodySize))
+}
+
+func newHAREntry(req *http.Request, ex *Exchange, v *harValidation, limit int64) *harEntry {
+	u := *req.URL
+	if u.Host == "" {
+		u.Host = req.Host
+	}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if req.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+
+	var cookies []harNameValue
+	for _, c := range req.Cookies() {
+		cookies = append(cookies, harNameValue{Name: c.Name, Value: c.Value})
+	}
+
+	var query []harNameValue
+	for name, values := range u.Query() {
+		for _, value := range values {
+			query = append(query, harNameValue{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(query, func(i, j int) bool { return query[i].Name < query[j].Name })
+
+	entry := &harEntry{
+		StartedDateTime: ex.start,
+		Time:            milliseconds(ex.Duration),
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         u.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     nonNil(cookies),
+			Headers:     harHeaders(req.Header),
+			QueryString: nonNil(query),
+			HeadersSize: -1,
+			BodySize:    len(ex.requestBody),
+		},
+		Timings:    harTimings{Wait: milliseconds(ex.Duration)},
+		Validation: v,
+	}
+	if len(ex.requestBody) > 0 {
+		entry.Request.PostData = &harPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     string(ex.requestBody),
+		}
+	}
+
+	resp := ex.response
+	// HAR content is stored decoded
+	body, err := decodeBody(resp, limit)
+	if err != nil {
+		body = resp.Body()
+	}
+	text, encoding := harBody(body)
+	entry.Response = harResponse{
+		Status:      ex.Status,
+		StatusText:  http.StatusText(ex.Status),
+		HTTPVersion: req.Proto,
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(resp.Header()),
+		Content: harContent{
+			Size:     len(body),
+			MimeType: resp.Header().Get("Content-Type"),
+			Text:     text,
+			Encoding: encoding,
+		},
+		HeadersSize: -1,
+		BodySize:    len(resp.Body()),
+	}
+	return entry
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for name, values := range header {
+		for _, value := range values {
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(headers, func(i, j int) bool { return headers[i].Name < headers[j].Name })
+	return headers
+}
+
+// harBody returns the text of a body, base64 encoded when it isn't valid
+// UTF-8.
+func harBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func nonNil(list []harNameValue) []harNameValue {
+	if list == nil {
+		return []harNameValue{}
+	}
+	return list
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateHAR(t *testing.T) {
+	reporter := &testReporter{}
+	app, err := New(openFixture(t, "petstore.json"), reporter)
+	require.NoError(t, err)
+
+	f, err := os.Open("./fixtures/traffic.har")
+	require.NoError(t, err)
+	defer f.Close()
+
+	require.NoError(t, app.ValidateHAR(f))
+
+	assert.Len(t, reporter.success, 1)
+	require.Len(t, reporter.errors, 1)
+	assert.Contains(t, reporter.errors[0].Error(), "id")
+	assert.Equal(t, []string{"Route not defined on the Spec"}, reporter.warnings)
+
+	for _, op := range app.PendingOperations() {
+		assert.NotEqual(t, "getInventory", op.ID)
+		assert.NotEqual(t, "getPetById", op.ID)
+	}
+	assert.Len(t, app.PendingOperations(), 18)
+}
+
+func TestHARRecorder(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/v2/store/inventory":
+			w.Write([]byte(`{"sold": 1}`))
+		case "/v2/pet/1":
+			w.Write([]byte(`{"id": "x"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer target.Close()
+
+	var buf bytes.Buffer
+	recorder := NewHARRecorder(&buf)
+	app, err := New(openFixture(t, "petstore.json"), &testReporter{},
+		WithTarget(target.URL),
+		WithHARRecorder(recorder),
+	)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(app.Router())
+	defer srv.Close()
+
+	http.Get(srv.URL + "/v2/store/inventory?x=1")
+	http.Get(srv.URL + "/v2/pet/1")
+	http.Post(srv.URL+"/v2/store/order", "application/json", strings.NewReader(`{"id": 1}`))
+	http.Get(srv.URL + "/v2/undefined")
+	require.NoError(t, recorder.Close())
+
+	var doc har
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	require.Len(t, doc.Log.Entries, 4)
+	assert.Equal(t, "1.2", doc.Log.Version)
+
+	inventory := doc.Log.Entries[0]
+	assert.Equal(t, srv.URL+"/v2/store/inventory?x=1", inventory.Request.URL)
+	assert.Equal(t, []harNameValue{{"x", "1"}}, inventory.Request.QueryString)
+	assert.Equal(t, `{"sold": 1}`, inventory.Response.Content.Text)
+	assert.Equal(t, "application/json", inventory.Response.Content.MimeType)
+	assert.Equal(t, "success", inventory.Validation.Outcome)
+	assert.Equal(t, "getInventory", inventory.Validation.OperationID)
+
+	pet := doc.Log.Entries[1]
+	assert.Equal(t, "error", pet.Validation.Outcome)
+	assert.NotEmpty(t, pet.Validation.Errors)
+
+	order := doc.Log.Entries[2]
+	require.NotNil(t, order.Request.PostData)
+	assert.Equal(t, `{"id": 1}`, order.Request.PostData.Text)
+
+	assert.Equal(t, "warning", doc.Log.Entries[3].Validation.Outcome)
+
+	t.Run("Replay", func(t *testing.T) {
+		reporter := &testReporter{}
+		app, err := New(openFixture(t, "petstore.json"), reporter)
+		require.NoError(t, err)
+
+		require.NoError(t, app.ValidateHAR(&buf))
+		assert.Len(t, reporter.success, 1)
+		assert.Len(t, reporter.errors, 2)
+		assert.Len(t, reporter.warnings, 1)
+	})
+}
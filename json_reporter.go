@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// JSONReporter writes one JSON object per exchange, one per line.
+type JSONReporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+type jsonRecord struct {
+	Time          time.Time   `json:"time"`
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	Path          string      `json:"path,omitempty"`
+	OperationID   string      `json:"operationId,omitempty"`
+	Status        int         `json:"status,omitempty"`
+	Outcome       string      `json:"outcome"`
+	Errors        []Violation `json:"errors,omitempty"`
+	RequestErrors []Violation `json:"requestErrors,omitempty"`
+	Warning       string      `json:"warning,omitempty"`
+}
+
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{enc: json.NewEncoder(w)}
+}
+
+func (r *JSONReporter) Success(req *http.Request) {
+	r.write(req, &jsonRecord{Outcome: "success"})
+}
+
+func (r *JSONReporter) Error(req *http.Request, err error) {
+	r.write(req, &jsonRecord{Outcome: "error", Errors: Violations(err)})
+}
+
+func (r *JSONReporter) Warning(req *http.Request, msg string) {
+	r.write(req, &jsonRecord{Outcome: "warning", Warning: msg})
+}
+
+func (r *JSONReporter) Report() {}
+
+func (r *JSONReporter) write(req *http.Request, rec *jsonRecord) {
+	rec.Time = time.Now()
+	rec.Method = req.Method
+	rec.URL = req.URL.String()
+	if ex := ExchangeOf(req); ex != nil {
+		rec.Path = ex.Path
+		rec.Status = ex.Status
+		rec.RequestErrors = Violations(ex.RequestErr)
+		if ex.Operation != nil {
+			rec.OperationID = ex.Operation.ID
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.enc.Encode(rec)
+}
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONReporter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	app, err := New(openFixture(t, "petstore.json"), NewJSONReporter(buf))
+	require.NoError(t, err)
+
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"name": "doggie"}]`))
+	}
+
+	srv := httptest.NewServer(app.Handler(http.HandlerFunc(fn)))
+	defer srv.Close()
+
+	http.Get(srv.URL + "/v2/pet/findByStatus")
+	http.Get(srv.URL + "/not_a_registered_url")
+
+	dec := json.NewDecoder(buf)
+
+	var rec jsonRecord
+	require.NoError(t, dec.Decode(&rec))
+	assert.Equal(t, "GET", rec.Method)
+	assert.Equal(t, "/v2/pet/findByStatus", rec.URL)
+	assert.Equal(t, "/pet/findByStatus", rec.Path)
+	assert.Equal(t, "findPetsByStatus", rec.OperationID)
+	assert.Equal(t, 200, rec.Status)
+	assert.Equal(t, "error", rec.Outcome)
+	require.Len(t, rec.RequestErrors, 1)
+	assert.Equal(t, "required", rec.RequestErrors[0].Kind)
+	assert.Equal(t, "query", rec.RequestErrors[0].In)
+	require.NotEmpty(t, rec.Errors)
+	assert.Equal(t, "/photoUrls", rec.Errors[0].Pointer)
+
+	rec = jsonRecord{}
+	require.NoError(t, dec.Decode(&rec))
+	assert.Equal(t, "warning", rec.Outcome)
+	assert.Empty(t, rec.OperationID)
+}
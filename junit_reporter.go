@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-openapi/spec"
+)
+
+// JUnitReporter accumulates the results per operation and writes them as a
+// JUnit XML test suite when Report is called. Every operation is a testcase
+// which fails if any of its exchanges did. Operations are keyed by method and
+// path, as in the stats, so they survive spec reloads.
+type JUnitReporter struct {
+	// Pending, when set, lists the operations to be reported as skipped.
+	Pending func() []*spec.Operation
+
+	mu    sync.Mutex
+	w     io.Writer
+	cases map[string]*junitCase
+}
+
+type junitCase struct {
+	name      string
+	classname string
+	exchanges int
+	failures  []string
+}
+
+type junitTestSuite struct {
+	XMLName  xml.Name        `xml:"testsuite"`
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func NewJUnitReporter(w io.Writer) *JUnitReporter {
+	return &JUnitReporter{
+		w:     w,
+		cases: make(map[string]*junitCase),
+	}
+}
+
+func (r *JUnitReporter) Success(req *http.Request) {
+	r.record(req, nil)
+}
+
+func (r *JUnitReporter) Error(req *http.Request, err error) {
+	r.record(req, err)
+}
+
+// Warning counts the exchange as passing, but for its request violations.
+// Requests which don't match any operation aren't part of the suite.
+func (r *JUnitReporter) Warning(req *http.Request, msg string) {
+	r.record(req, nil)
+}
+
+func (r *JUnitReporter) record(req *http.Request, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ex := ExchangeOf(req)
+	if ex == nil || ex.Operation == nil {
+		return
+	}
+	reqErr := ex.RequestErr
+
+	key := operationKey(req.Method, ex.Path)
+	c, ok := r.cases[key]
+	if !ok {
+		c = &junitCase{
+			name:      OperationName(ex.Operation),
+			classname: key,
+		}
+		r.cases[key] = c
+	}
+	c.exchanges++
+
+	if err == nil && reqErr == nil {
+		return
+	}
+
+	var lines []string
+	for _, v := range Violations(reqErr) {
+		lines = append(lines, "  request: "+v.Message)
+	}
+	for _, v := range Violations(err) {
+		lines = append(lines, "  response: "+v.Message)
+	}
+	c.failures = append(c.failures, fmt.Sprintf("%s %s (%d)\n%s",
+		req.Method, req.URL, ex.Status, strings.Join(lines, "\n"),
+	))
+}
+
+// Report writes the test suite.
+func (r *JUnitReporter) Report() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	suite := junitTestSuite{Name: "swagger-proxy"}
+	for _, c := range r.cases {
+		tc := junitTestCase{Name: c.name, ClassName: c.classname}
+		if len(c.failures) > 0 {
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d of %d exchanges failed", len(c.failures), c.exchanges),
+				Body:    strings.Join(c.failures, "\n"),
+			}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if r.Pending != nil {
+		for _, op := range r.Pending() {
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      OperationName(op),
+				ClassName: "pending",
+				Skipped:   &struct{}{},
+			})
+			suite.Skipped++
+		}
+	}
+	suite.Tests = len(suite.Cases)
+
+	sort.Slice(suite.Cases, func(i, j int) bool {
+		return suite.Cases[i].Name < suite.Cases[j].Name
+	})
+
+	io.WriteString(r.w, xml.Header)
+	enc := xml.NewEncoder(r.w)
+	enc.Indent("", "  ")
+	enc.Encode(suite)
+	io.WriteString(r.w, "\n")
+}
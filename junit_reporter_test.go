@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJUnitReporter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	reporter := NewJUnitReporter(buf)
+	app, err := New(openFixture(t, "petstore.json"), reporter)
+	require.NoError(t, err)
+	reporter.Pending = app.PendingOperations
+
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}
+
+	srv := httptest.NewServer(app.Handler(http.HandlerFunc(fn)))
+	defer srv.Close()
+
+	http.Get(srv.URL + "/v2/store/inventory")
+	http.Get(srv.URL + "/v2/pet/findByStatus?status=sold")
+	http.Get(srv.URL + "/v2/pet/findByStatus?status=sold")
+	reporter.Report()
+
+	var suite junitTestSuite
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suite))
+	assert.Equal(t, len(app.PendingOperations())+2, suite.Tests)
+	assert.Equal(t, 1, suite.Failures)
+	assert.Equal(t, len(app.PendingOperations()), suite.Skipped)
+
+	cases := make(map[string]junitTestCase)
+	for _, tc := range suite.Cases {
+		cases[tc.Name] = tc
+	}
+
+	assert.Nil(t, cases["getInventory"].Failure)
+	require.NotNil(t, cases["findPetsByStatus"].Failure)
+	assert.Equal(t, "2 of 2 exchanges failed", cases["findPetsByStatus"].Failure.Message)
+	assert.NotNil(t, cases["addPet"].Skipped)
+}
+
+func TestJUnitReporterWarnings(t *testing.T) {
+	buf := &bytes.Buffer{}
+	reporter := NewJUnitReporter(buf)
+	app, err := New(openFixture(t, "petstore.json"), reporter, WithMaxBodySize(1))
+	require.NoError(t, err)
+
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[]"))
+	}
+
+	// The status is missing and the body too large to be validated
+	req := httptest.NewRequest("GET", "/v2/pet/findByStatus", nil)
+	app.Handler(http.HandlerFunc(fn)).ServeHTTP(httptest.NewRecorder(), req)
+	req = httptest.NewRequest("GET", "/v2/unknown", nil)
+	app.Handler(http.HandlerFunc(fn)).ServeHTTP(httptest.NewRecorder(), req)
+	reporter.Report()
+
+	var suite junitTestSuite
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suite))
+	require.Len(t, suite.Cases, 1)
+	assert.Equal(t, "findPetsByStatus", suite.Cases[0].Name)
+	require.NotNil(t, suite.Cases[0].Failure)
+	assert.Contains(t, suite.Cases[0].Failure.Body, "request: ")
+}
+
+func TestJUnitReporterSpecReload(t *testing.T) {
+	buf := &bytes.Buffer{}
+	reporter := NewJUnitReporter(buf)
+	app, err := New(openFixture(t, "petstore.json"), reporter)
+	require.NoError(t, err)
+
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}
+
+	get := func() {
+		req := httptest.NewRequest("GET", "/v2/store/inventory", nil)
+		app.Handler(http.HandlerFunc(fn)).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	get()
+	require.NoError(t, app.SetSpec(openFixture(t, "petstore.json")))
+	get()
+	reporter.Report()
+
+	var suite junitTestSuite
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suite))
+	require.Len(t, suite.Cases, 1)
+	assert.Equal(t, "getInventory", suite.Cases[0].Name)
+	assert.Equal(t, "GET /store/inventory", suite.Cases[0].ClassName)
+}
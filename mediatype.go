@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type mediaType struct {
+	tpe, subtype string
+	params       map[string]string
+}
+
+func parseMediaType(s string) (mediaType, bool) {
+	full, params, err := mime.ParseMediaType(s)
+	if err != nil {
+		// Keep going with malformed parameters
+		full = strings.ToLower(strings.TrimSpace(strings.Split(s, ";")[0]))
+		params = nil
+	}
+
+	parts := strings.SplitN(full, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		if full == "*" {
+			return mediaType{tpe: "*", subtype: "*"}, true
+		}
+		return mediaType{}, false
+	}
+	return mediaType{tpe: parts[0], subtype: parts[1], params: params}, true
+}
+
+// suffix returns the structured syntax suffix of the subtype (json for
+// vnd.api+json), if any.
+func (m mediaType) suffix() string {
+	if i := strings.LastIndex(m.subtype, "+"); i != -1 {
+		return m.subtype[i+1:]
+	}
+	return ""
+}
+
+// matches reports whether the media type m, which can be a range such as
+// */*, application/* or application/*+json, matches actual. Types with a
+// structured syntax suffix match their base type: application/problem+json
+// matches application/json. Parameters in m must be present in actual.
+func (m mediaType) matches(actual mediaType) bool {
+	if m.tpe != "*" && m.tpe != actual.tpe {
+		return false
+	}
+
+	switch {
+	case m.subtype == "*", m.subtype == actual.subtype:
+	case strings.HasPrefix(m.subtype, "*+"):
+		if actual.suffix() != m.subtype[2:] {
+			return false
+		}
+	case actual.suffix() != "" && actual.suffix() == m.subtype:
+	default:
+		return false
+	}
+
+	for key, value := range m.params {
+		if key == "q" {
+			continue
+		}
+		if !strings.EqualFold(actual.params[key], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// mediaTypeMatches reports whether the media type actual is matched by any
+// of the media types (or ranges) in list.
+func mediaTypeMatches(list []string, actual string) bool {
+	a, ok := parseMediaType(actual)
+	if !ok {
+		return false
+	}
+
+	for _, s := range list {
+		if m, ok := parseMediaType(s); ok && m.matches(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptedMediaTypes returns the media ranges accepted by a request, leaving
+// out those with a q=0 quality.
+func acceptedMediaTypes(req *http.Request) []string {
+	var accepted []string
+	for _, header := range req.Header["Accept"] {
+		for _, s := range strings.Split(header, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			if m, ok := parseMediaType(s); ok {
+				if q, err := strconv.ParseFloat(m.params["q"], 64); err == nil && q == 0 {
+					continue
+				}
+			}
+			accepted = append(accepted, s)
+		}
+	}
+	return accepted
+}
+
+// validateAccept checks a successful response against the Accept header of
+// the request.
+func validateAccept(req *http.Request, resp Response) error {
+	if resp.Status() < 200 || resp.Status() >= 300 {
+		return nil
+	}
+
+	ct := resp.Header().Get("Content-Type")
+	if ct == "" || len(req.Header["Accept"]) == 0 {
+		return nil
+	}
+
+	accepted := acceptedMediaTypes(req)
+	if mediaTypeMatches(accepted, ct) {
+		return nil
+	}
+	return fmt.Errorf("Content-Type Error: Request accepts %q, but got: '%s'", accepted, ct)
+}
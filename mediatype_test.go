@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMediaTypeMatches(t *testing.T) {
+	for _, test := range []struct {
+		produces string
+		ct       string
+		match    bool
+	}{
+		{"application/json", "application/json", true},
+		{"application/json", "application/json; charset=utf-8", true},
+		{"application/json", "Application/JSON", true},
+		{"application/json", "application/xml", false},
+		{"application/json", "", false},
+		{"application/json; charset=utf-8", "application/json; charset=UTF-8", true},
+		{"application/json; charset=utf-8", "application/json", false},
+		{"application/*", "application/xml", true},
+		{"application/*", "text/plain", false},
+		{"*/*", "text/plain", true},
+		{"application/json", "application/problem+json", true},
+		{"application/xml", "application/atom+xml", true},
+		{"application/xml", "application/problem+json", false},
+		{"application/*+json", "application/vnd.api+json", true},
+		{"application/*+json", "application/json", false},
+	} {
+		assert.Equal(t, test.match, mediaTypeMatches([]string{test.produces}, test.ct),
+			"%q matches %q", test.produces, test.ct,
+		)
+	}
+}
+
+func TestAccept(t *testing.T) {
+	reporter := &testReporter{}
+	app, err := New(openFixture(t, "petstore.json"), reporter)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(app.Handler(
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte("{}"))
+		}),
+	))
+	defer srv.Close()
+
+	get := func(accept string) {
+		req, err := http.NewRequest("GET", srv.URL+"/v2/store/inventory", nil)
+		require.NoError(t, err)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	get("")
+	get("application/json")
+	get("text/html, application/*;q=0.8")
+	assert.Len(t, reporter.success, 3)
+	assert.Len(t, reporter.errors, 0)
+
+	get("application/xml")
+	get("application/json;q=0, */*;q=0")
+	assert.Len(t, reporter.errors, 2)
+}
@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the upstream latency
+// histogram.
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type exchangeLabels struct {
+	operationID string
+	method      string
+	status      int
+	outcome     string
+}
+
+type operationLabels struct {
+	operationID string
+	method      string
+}
+
+type histogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, le := range latencyBuckets {
+		if v <= le {
+			h.buckets[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// metrics holds the Prometheus metrics of the Proxy.
+type metrics struct {
+	mu            sync.Mutex
+	exchanges     map[exchangeLabels]uint64
+	requestErrors map[operationLabels]uint64
+	latency       map[operationLabels]*histogram
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		exchanges:     make(map[exchangeLabels]uint64),
+		requestErrors: make(map[operationLabels]uint64),
+		latency:       make(map[operationLabels]*histogram),
+	}
+}
+
+func labelsOf(req *http.Request) (operationLabels, *Exchange) {
+	labels := operationLabels{method: req.Method}
+	ex := ExchangeOf(req)
+	if ex != nil && ex.Operation != nil {
+		labels.operationID = ex.Operation.ID
+	}
+	return labels, ex
+}
+
+func (m *metrics) outcome(req *http.Request, outcome string) {
+	labels, ex := labelsOf(req)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := 0
+	if ex != nil {
+		status = ex.Status
+
+		h, ok := m.latency[labels]
+		if !ok {
+			h = &histogram{buckets: make([]uint64, len(latencyBuckets))}
+			m.latency[labels] = h
+		}
+		h.observe(ex.Duration.Seconds())
+	}
+
+	m.exchanges[exchangeLabels{labels.operationID, labels.method, status, outcome}]++
+}
+
+func (m *metrics) requestError(req *http.Request) {
+	labels, _ := labelsOf(req)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requestErrors[labels]++
+}
+
+// MetricsHandler serves the metrics in the Prometheus text format.
+func (proxy *Proxy) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		proxy.metrics.write(w, len(proxy.PendingOperations()))
+	})
+}
+
+func (m *metrics) write(w io.Writer, pending int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP swagger_proxy_exchanges_total Exchanges validated by the proxy.")
+	fmt.Fprintln(w, "# TYPE swagger_proxy_exchanges_total counter")
+	var lines []string
+	for l, v := range m.exchanges {
+		lines = append(lines, fmt.Sprintf("swagger_proxy_exchanges_total{operation_id=%s,method=%s,status=%s,outcome=%s} %d",
+			quote(l.operationID), quote(l.method), quote(strconv.Itoa(l.status)), quote(l.outcome), v,
+		))
+	}
+	writeSorted(w, lines)
+
+	fmt.Fprintln(w, "# HELP swagger_proxy_request_errors_total Requests which didn't conform to the spec.")
+	fmt.Fprintln(w, "# TYPE swagger_proxy_request_errors_total counter")
+	lines = nil
+	for l, v := range m.requestErrors {
+		lines = append(lines, fmt.Sprintf("swagger_proxy_request_errors_total{operation_id=%s,method=%s} %d",
+			quote(l.operationID), quote(l.method), v,
+		))
+	}
+	writeSorted(w, lines)
+
+	fmt.Fprintln(w, "# HELP swagger_proxy_pending_operations Operations not exercised yet.")
+	fmt.Fprintln(w, "# TYPE swagger_proxy_pending_operations gauge")
+	fmt.Fprintf(w, "swagger_proxy_pending_operations %d\n", pending)
+
+	fmt.Fprintln(w, "# HELP swagger_proxy_upstream_duration_seconds Time taken by the upstream to respond.")
+	fmt.Fprintln(w, "# TYPE swagger_proxy_upstream_duration_seconds histogram")
+	var keys []operationLabels
+	for l := range m.latency {
+		keys = append(keys, l)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].operationID != keys[j].operationID {
+			return keys[i].operationID < keys[j].operationID
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	for _, l := range keys {
+		h := m.latency[l]
+		labels := fmt.Sprintf("operation_id=%s,method=%s", quote(l.operationID), quote(l.method))
+		for i, le := range latencyBuckets {
+			fmt.Fprintf(w, "swagger_proxy_upstream_duration_seconds_bucket{%s,le=%s} %d\n",
+				labels, quote(strconv.FormatFloat(le, 'g', -1, 64)), h.buckets[i],
+			)
+		}
+		fmt.Fprintf(w, "swagger_proxy_upstream_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(w, "swagger_proxy_upstream_duration_seconds_sum{%s} %g\n", labels, h.sum)
+		fmt.Fprintf(w, "swagger_proxy_upstream_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+}
+
+func writeSorted(w io.Writer, lines []string) {
+	sort.Strings(lines)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quote(s string) string {
+	return `"` + labelEscaper.Replace(s) + `"`
+}
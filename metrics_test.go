@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	app, err := New(openFixture(t, "petstore.json"), &testReporter{})
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(app.Handler(
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("{}"))
+		}),
+	))
+	defer srv.Close()
+
+	http.Get(srv.URL + "/v2/store/inventory")
+	http.Get(srv.URL + "/v2/store/inventory")
+	http.Get(srv.URL + "/v2/pet/findByStatus")
+	http.Get(srv.URL + "/undefined")
+
+	w := httptest.NewRecorder()
+	app.MetricsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+
+	body, err := ioutil.ReadAll(w.Body)
+	require.NoError(t, err)
+	metrics := string(body)
+
+	for _, line := range []string{
+		`swagger_proxy_exchanges_total{operation_id="getInventory",method="GET",status="200",outcome="success"} 2`,
+		`swagger_proxy_exchanges_total{operation_id="findPetsByStatus",method="GET",status="200",outcome="error"} 1`,
+		`swagger_proxy_exchanges_total{operation_id="",method="GET",status="200",outcome="warning"} 1`,
+		`swagger_proxy_request_errors_total{operation_id="findPetsByStatus",method="GET"} 1`,
+		`swagger_proxy_pending_operations 18`,
+		`# TYPE swagger_proxy_upstream_duration_seconds histogram`,
+		`swagger_proxy_upstream_duration_seconds_bucket{operation_id="getInventory",method="GET",le="+Inf"} 2`,
+		`swagger_proxy_upstream_duration_seconds_count{operation_id="getInventory",method="GET"} 2`,
+	} {
+		assert.Contains(t, metrics, line+"\n")
+	}
+}
@@ -0,0 +1,479 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/spec"
+)
+
+// maxMockDepth bounds the synthesis of recursive schemas.
+const maxMockDepth = 8
+
+// mockHandler answers the operation with a response synthesized from the
+// spec. The status can be chosen by the client with a `Prefer: code=404`
+// header, otherwise the first success response is used.
+func (st *state) mockHandler(op *spec.Operation) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		status, resp := st.mockResponse(op, preferredStatus(req))
+		mime, jsonBody := st.mockMIME(op)
+		w.Header().Set("Content-Type", mime)
+		if resp == nil {
+			w.WriteHeader(status)
+			return
+		}
+
+		for name, header := range resp.Headers {
+			value, err := mockHeader(&header)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("%s header: %s", name, err), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set(name, value)
+		}
+
+		data, err := st.mockBody(resp, mime, jsonBody)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(status)
+		w.Write(data)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// preferredStatus returns the status requested by a `Prefer: code=xxx`
+// header, or 0.
+func preferredStatus(req *http.Request) int {
+	for _, value := range req.Header["Prefer"] {
+		for _, pref := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+			kv := strings.SplitN(strings.TrimSpace(pref), "=", 2)
+			if len(kv) != 2 || kv[0] != "code" {
+				continue
+			}
+			if code, err := strconv.Atoi(strings.Trim(kv[1], `"`)); err == nil {
+				return code
+			}
+		}
+	}
+	return 0
+}
+
+func (st *state) mockResponse(op *spec.Operation, preferred int) (int, *spec.Response) {
+	if preferred != 0 {
+		if resp, ok := operationResponse(op, preferred); ok {
+			return preferred, resp
+		}
+	}
+
+	if op.Responses == nil {
+		return http.StatusOK, nil
+	}
+
+	var codes []int
+	for code := range op.Responses.StatusCodeResponses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	for _, code := range codes {
+		if code >= 200 && code < 300 {
+			resp := op.Responses.StatusCodeResponses[code]
+			return code, &resp
+		}
+	}
+
+	if len(codes) > 0 {
+		resp := op.Responses.StatusCodeResponses[codes[0]]
+		return codes[0], &resp
+	}
+
+	if op.Responses.Default != nil {
+		return http.StatusOK, op.Responses.Default
+	}
+	return http.StatusOK, nil
+}
+
+// mockMIME returns the media type of the mocked responses, and whether it
+// is JSON. Only JSON bodies can be synthesized, the first type produced is
+// used when the operation doesn't produce any.
+func (st *state) mockMIME(op *spec.Operation) (string, bool) {
+	produces := op.Produces
+	if len(produces) == 0 {
+		produces = st.spec.Produces
+	}
+	if len(produces) == 0 {
+		return "application/json", true
+	}
+
+	for _, mime := range produces {
+		if isJSON(mime) {
+			return mime, true
+		}
+	}
+	return produces[0], false
+}
+
+// mockBody returns the body for resp, looking for an example first and
+// synthesizing it from the schema otherwise. Bodies which aren't JSON are
+// only returned from a string example, so they might be empty.
+func (st *state) mockBody(resp *spec.Response, mime string, jsonBody bool) ([]byte, error) {
+	if !jsonBody {
+		s, _ := resp.Examples[mime].(string)
+		return []byte(s), nil
+	}
+
+	for mime, example := range resp.Examples {
+		if isJSON(mime) {
+			return json.Marshal(example)
+		}
+	}
+
+	if resp.Schema == nil {
+		return nil, nil
+	}
+	body, err := st.mockValue(resp.Schema, 0)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(body)
+}
+
+func (st *state) mockValue(schema *spec.Schema, depth int) (interface{}, error) {
+	schema = st.resolveSchema(schema)
+
+	switch {
+	case schema.Example != nil:
+		return schema.Example, nil
+	case schema.Default != nil:
+		return schema.Default, nil
+	case len(schema.Enum) > 0:
+		return schema.Enum[0], nil
+	case len(schema.AllOf) > 0:
+		obj := make(map[string]interface{})
+		for i := range schema.AllOf {
+			v, err := st.mockValue(&schema.AllOf[i], depth)
+			if err != nil {
+				return nil, err
+			}
+			if m, ok := v.(map[string]interface{}); ok {
+				for k, v := range m {
+					obj[k] = v
+				}
+			}
+		}
+		return obj, nil
+	case len(schema.OneOf) > 0:
+		return st.mockValue(&schema.OneOf[0], depth)
+	case len(schema.AnyOf) > 0:
+		return st.mockValue(&schema.AnyOf[0], depth)
+	}
+
+	tpe := ""
+	for _, t := range schema.Type {
+		if t != "null" {
+			tpe = t
+			break
+		}
+	}
+	if tpe == "" && len(schema.Properties) > 0 {
+		tpe = "object"
+	}
+
+	v := schemaValidations(schema)
+	switch tpe {
+	case "object":
+		obj := make(map[string]interface{})
+		if depth >= maxMockDepth {
+			return obj, nil
+		}
+		for name, prop := range schema.Properties {
+			value, err := st.mockValue(&prop, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err)
+			}
+			obj[name] = value
+		}
+		return obj, nil
+	case "array":
+		items := []interface{}{}
+		if depth >= maxMockDepth || schema.Items == nil || schema.Items.Schema == nil {
+			return items, nil
+		}
+		n := 1
+		if schema.MinItems != nil && *schema.MinItems > 1 {
+			n = int(*schema.MinItems)
+		}
+		if schema.MaxItems != nil && int64(n) > *schema.MaxItems {
+			n = int(*schema.MaxItems)
+		}
+		for i := 0; i < n; i++ {
+			item, err := st.mockValue(schema.Items.Schema, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case "integer":
+		n, err := mockNumber(v, true)
+		return int64(n), err
+	case "number":
+		return mockNumber(v, false)
+	case "boolean":
+		return true, nil
+	case "string":
+		return mockString(schema.Format, v)
+	}
+	return nil, nil
+}
+
+// schemaValidations returns the validations of a schema which also apply to
+// headers.
+func schemaValidations(schema *spec.Schema) *spec.CommonValidations {
+	return &spec.CommonValidations{
+		Maximum:          schema.Maximum,
+		ExclusiveMaximum: schema.ExclusiveMaximum,
+		Minimum:          schema.Minimum,
+		ExclusiveMinimum: schema.ExclusiveMinimum,
+		MaxLength:        schema.MaxLength,
+		MinLength:        schema.MinLength,
+		Pattern:          schema.Pattern,
+		MultipleOf:       schema.MultipleOf,
+	}
+}
+
+// resolveSchema returns the schema a reference points to in the spec, following
+// chains of references. Unresolvable ones are returned as they are, and are
+// mocked or matched as an empty schema.
+func (st *state) resolveSchema(schema *spec.Schema) *spec.Schema {
+	for i := 0; i < maxMockDepth && schema.Ref.String() != ""; i++ {
+		resolved, err := spec.ResolveRef(st.spec, &schema.Ref)
+		if err != nil {
+			break
+		}
+		schema = resolved
+	}
+	return schema
+}
+
+// mockNumber returns a number within the bounds of v, preferably 0 or the
+// closest to the minimum, or an error if there is none.
+func mockNumber(v *spec.CommonValidations, integer bool) (float64, error) {
+	min, max := v.Minimum, v.Maximum
+	if min != nil && max != nil && (*max < *min || *max == *min && (v.ExclusiveMinimum || v.ExclusiveMaximum)) {
+		return 0, fmt.Errorf("no number between minimum %v and maximum %v", *min, *max)
+	}
+
+	step := 0.0
+	if integer {
+		step = 1
+	}
+	if v.MultipleOf != nil && *v.MultipleOf > 0 {
+		step = *v.MultipleOf
+	}
+
+	n := 0.0
+	switch {
+	case min != nil && (*min > 0 || *min == 0 && v.ExclusiveMinimum):
+		n = roundUp(*min, step)
+		if n == *min && v.ExclusiveMinimum {
+			n = nextAbove(n, step, max)
+		}
+	case max != nil && (*max < 0 || *max == 0 && v.ExclusiveMaximum):
+		n = roundDown(*max, step)
+		if n == *max && v.ExclusiveMaximum {
+			var lower *float64
+			if min != nil {
+				lower = new(float64)
+				*lower = -*min
+			}
+			n = -nextAbove(-n, step, lower)
+		}
+	}
+
+	if min != nil && (n < *min || n == *min && v.ExclusiveMinimum) ||
+		max != nil && (n > *max || n == *max && v.ExclusiveMaximum) {
+		return 0, fmt.Errorf("no multiple of %v within the minimum and maximum", step)
+	}
+	return n, nil
+}
+
+// roundUp returns the lowest multiple of step from n, or n without step.
+func roundUp(n, step float64) float64 {
+	if step == 0 {
+		return n
+	}
+	return math.Ceil(n/step) * step
+}
+
+func roundDown(n, step float64) float64 {
+	if step == 0 {
+		return n
+	}
+	return math.Floor(n/step) * step
+}
+
+// nextAbove returns the next multiple of step after n, or a number between
+// n and max without step.
+func nextAbove(n, step float64, max *float64) float64 {
+	switch {
+	case step != 0:
+		return n + step
+	case max != nil:
+		return n + (*max-n)/2
+	}
+	return n + 1
+}
+
+// mockString returns a string of the format matching v, or an error if
+// there is none.
+func mockString(format string, v *spec.CommonValidations) (string, error) {
+	if v.Pattern != "" {
+		return mockPattern(v.Pattern)
+	}
+
+	switch format {
+	case "date-time":
+		return time.Now().UTC().Format(time.RFC3339), nil
+	case "date":
+		return time.Now().UTC().Format("2006-01-02"), nil
+	case "uuid":
+		return "00000000-0000-4000-8000-000000000000", nil
+	case "email":
+		return "user@example.com", nil
+	case "uri", "url":
+		return "http://example.com", nil
+	case "hostname":
+		return "example.com", nil
+	case "ipv4":
+		return "127.0.0.1", nil
+	case "ipv6":
+		return "::1", nil
+	case "byte":
+		return "c3RyaW5n", nil
+	}
+
+	if v.MinLength != nil && v.MaxLength != nil && *v.MaxLength < *v.MinLength {
+		return "", fmt.Errorf("no string between minLength %d and maxLength %d", *v.MinLength, *v.MaxLength)
+	}
+
+	s := "string"
+	if v.MinLength != nil && int64(len(s)) < *v.MinLength {
+		s = strings.Repeat(s, int(*v.MinLength)/len(s)+1)[:*v.MinLength]
+	}
+	if v.MaxLength != nil && int64(len(s)) > *v.MaxLength {
+		s = s[:*v.MaxLength]
+	}
+	return s, nil
+}
+
+// mockPattern returns a string matching pattern, taking the first choice of
+// every alternation or class and repeating as few times as possible.
+func mockPattern(pattern string) (string, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", fmt.Errorf("pattern %q can't be mocked: %s", pattern, err)
+	}
+
+	var b strings.Builder
+	writePattern(&b, re.Simplify())
+	return b.String(), nil
+}
+
+func writePattern(b *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		b.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		b.WriteRune(classRune(re.Rune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteRune('a')
+	case syntax.OpCapture, syntax.OpPlus:
+		writePattern(b, re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			writePattern(b, sub)
+		}
+	case syntax.OpAlternate:
+		writePattern(b, re.Sub[0])
+	case syntax.OpRepeat:
+		for i := 0; i < re.Min; i++ {
+			writePattern(b, re.Sub[0])
+		}
+	}
+}
+
+// classRune picks a rune of a class given as ranges, preferably a letter or
+// a digit.
+func classRune(ranges []rune) rune {
+	for _, pref := range [][2]rune{{'a', 'z'}, {'A', 'Z'}, {'0', '9'}, {' ', '~'}} {
+		for i := 0; i+1 < len(ranges); i += 2 {
+			lo, hi := ranges[i], ranges[i+1]
+			if lo <= pref[1] && hi >= pref[0] {
+				if lo < pref[0] {
+					return pref[0]
+				}
+				return lo
+			}
+		}
+	}
+	if len(ranges) > 0 {
+		return ranges[0]
+	}
+	return 'a'
+}
+
+func mockHeader(header *spec.Header) (string, error) {
+	return mockSimple(&header.SimpleSchema, &header.CommonValidations)
+}
+
+// mockSimple returns a value of a header, or of an item of an array header,
+// serialized as it goes on the wire.
+func mockSimple(s *spec.SimpleSchema, v *spec.CommonValidations) (string, error) {
+	if s.Default != nil {
+		return fmt.Sprint(s.Default), nil
+	}
+	if len(v.Enum) > 0 {
+		return fmt.Sprint(v.Enum[0]), nil
+	}
+
+	switch s.Type {
+	case "integer":
+		n, err := mockNumber(v, true)
+		return strconv.FormatInt(int64(n), 10), err
+	case "number":
+		n, err := mockNumber(v, false)
+		return strconv.FormatFloat(n, 'f', -1, 64), err
+	case "boolean":
+		return "true", nil
+	case "array":
+		item := "string"
+		if s.Items != nil {
+			var err error
+			if item, err = mockSimple(&s.Items.SimpleSchema, &s.Items.CommonValidations); err != nil {
+				return "", err
+			}
+		}
+
+		n := 1
+		if v.MinItems != nil && *v.MinItems > 1 {
+			n = int(*v.MinItems)
+		}
+		items := make([]string, n)
+		for i := range items {
+			items[i] = item
+		}
+		return strings.Join(items, collectionSeparator(s.CollectionFormat)), nil
+	}
+	return mockString(s.Format, v)
+}
@@ -0,0 +1,256 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/validate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMock(t *testing.T) {
+	reporter := &testReporter{}
+	app, err := New(openFixture(t, "petstore.json"), reporter, WithMock(true))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(app.Router())
+	defer srv.Close()
+
+	get := func(url string, prefer string) *http.Response {
+		req, err := http.NewRequest("GET", srv.URL+url, nil)
+		require.NoError(t, err)
+		if prefer != "" {
+			req.Header.Set("Prefer", prefer)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("SynthesizedBody", func(t *testing.T) {
+		resp := get("/v2/pet/1", "")
+		defer resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		var pet map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&pet))
+		assert.Equal(t, "doggie", pet["name"], "uses the schema example")
+		assert.Contains(t, pet, "photoUrls")
+	})
+
+	t.Run("Headers", func(t *testing.T) {
+		resp := get("/v2/user/login?username=foo&password=bar", "")
+		defer resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.NotEmpty(t, resp.Header.Get("X-Rate-Limit"))
+	})
+
+	t.Run("PreferredStatus", func(t *testing.T) {
+		resp := get("/v2/pet/1", "code=404")
+		defer resp.Body.Close()
+		assert.Equal(t, 404, resp.StatusCode)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		resp := get("/not_a_registered_url", "")
+		defer resp.Body.Close()
+		assert.Equal(t, 404, resp.StatusCode)
+	})
+
+	assert.Empty(t, reporter.errors, "mocked responses conform to the spec")
+	assert.Equal(t, 3, len(reporter.success))
+}
+
+func TestPartialMock(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/v2/store/inventory":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"from": 1}`))
+		case "/v2/user/logout":
+			w.WriteHeader(http.StatusNotImplemented)
+		case "/v2/store/order/1":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer target.Close()
+
+	reporter := &testReporter{}
+	app, err := New(openFixture(t, "petstore.json"), reporter,
+		WithTarget(target.URL),
+		WithMockOperations("getPetById"),
+		WithMockTags("user"),
+		WithMockUnimplemented(true),
+	)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(app.Router())
+	defer srv.Close()
+
+	for _, url := range []string{
+		"/v2/store/inventory",       // target
+		"/v2/pet/1",                 // mocked operation
+		"/v2/user/logout",           // mocked tag
+		"/v2/store/order/1",         // unimplemented, 404 is defined
+		"/v2/pet/findByTags?tags=a", // unimplemented
+	} {
+		resp, err := http.Get(srv.URL + url)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		if url == "/v2/store/order/1" {
+			assert.Equal(t, 404, resp.StatusCode, url)
+		} else {
+			assert.Equal(t, 200, resp.StatusCode, url)
+		}
+	}
+
+	assert.Empty(t, reporter.errors)
+	assert.Equal(t, 5, len(reporter.success))
+}
+
+func TestMockUnimplementedPassthrough(t *testing.T) {
+	read := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"sold": 1`))
+		w.(http.Flusher).Flush()
+
+		// The response must go through before the target is done
+		<-read
+		w.Write([]byte(`}`))
+	}))
+	defer target.Close()
+
+	reporter := &testReporter{}
+	app, err := New(openFixture(t, "petstore.json"), reporter,
+		WithTarget(target.URL),
+		WithMockUnimplemented(true),
+		WithMaxBodySize(5),
+	)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(app.Router())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v2/store/inventory")
+	require.NoError(t, err)
+	buf := make([]byte, 10)
+	_, err = io.ReadFull(resp.Body, buf)
+	require.NoError(t, err)
+	assert.Equal(t, `{"sold": 1`, string(buf))
+
+	read <- struct{}{}
+	rest, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "}", string(rest))
+
+	srv.Close()
+	assert.Equal(t, []string{"Body too large, not validated"}, reporter.warnings)
+}
+
+func TestMockConstraints(t *testing.T) {
+	app, err := New(openFixture(t, "petstore.json"), nil)
+	require.NoError(t, err)
+	st := app.current()
+
+	float := func(f float64) *float64 { return &f }
+
+	valid := []*spec.Schema{
+		spec.StringProperty().WithMaxLength(3),
+		spec.StringProperty().WithMinLength(10).WithMaxLength(12),
+		spec.StringProperty().WithPattern(`^[A-Z]{2}-\d{3}(-[a-z]+)?$`),
+		spec.StringProperty().WithPattern(`^(foo|bar)\.[^./]+$`),
+		spec.Int64Property().WithMinimum(0, true),
+		spec.Int64Property().WithMaximum(0, true),
+		spec.Int64Property().WithMinimum(1.5, false).WithMaximum(2.5, false),
+		spec.Int64Property().WithMinimum(10, true).WithMultipleOf(5),
+		spec.Float64Property().WithMinimum(1, true).WithMaximum(2, true),
+		spec.Float64Property().WithMinimum(-1, false).WithMaximum(0, true),
+		spec.Float64Property().WithMinimum(0.1, false).WithMultipleOf(0.25),
+		spec.ArrayProperty(spec.StringProperty()).WithMinItems(2).WithMaxItems(3),
+	}
+	for _, schema := range valid {
+		value, err := st.mockValue(schema, 0)
+		require.NoError(t, err)
+		assert.NoError(t, validate.AgainstSchema(schema, value, strfmt.Default), "%v", value)
+	}
+
+	invalid := []*spec.Schema{
+		spec.StringProperty().WithMinLength(5).WithMaxLength(3),
+		spec.StringProperty().WithPattern(`(?=a)`),
+		spec.Int64Property().WithMinimum(10, false).WithMaximum(1, false),
+		spec.Int64Property().WithMinimum(1, true).WithMaximum(2, true),
+		spec.Float64Property().WithMinimum(1, false).WithMaximum(1, true),
+		{SchemaProps: spec.SchemaProps{Type: spec.StringOrArray{"integer"}, Minimum: float(1), Maximum: float(4), MultipleOf: float(5)}},
+	}
+	for _, schema := range invalid {
+		_, err := st.mockValue(schema, 0)
+		assert.Error(t, err, "%#v", schema.SchemaProps)
+	}
+
+	header := spec.ResponseHeader().Typed("integer", "")
+	header.WithMaximum(0, true)
+	value, err := mockHeader(header)
+	require.NoError(t, err)
+	assert.Equal(t, "-1", value)
+
+	header.WithMinimum(0, true)
+	_, err = mockHeader(header)
+	assert.Error(t, err)
+
+	t.Run("ArrayHeaders", func(t *testing.T) {
+		ints := spec.ResponseHeader().CollectionOf(spec.NewItems().Typed("integer", "").WithMinimum(5, false), "pipes")
+		ints.WithMinItems(2)
+		uuids := spec.ResponseHeader().CollectionOf(spec.NewItems().Typed("string", "uuid"), "")
+
+		value, err = mockHeader(ints)
+		require.NoError(t, err)
+		assert.Equal(t, "5|5", value)
+		assert.NoError(t, validateHeaderValue("X-Ints", []string{value}, ints))
+
+		value, err = mockHeader(uuids)
+		require.NoError(t, err)
+		assert.NoError(t, validateHeaderValue("X-Uuids", []string{value}, uuids), value)
+	})
+}
+
+func TestMockMIME(t *testing.T) {
+	app, err := New(openFixture(t, "petstore.json"), nil)
+	require.NoError(t, err)
+	st := app.current()
+
+	op := spec.NewOperation("ping")
+	op.Produces = []string{"application/xml", "application/problem+json"}
+	mime, jsonBody := st.mockMIME(op)
+	assert.Equal(t, "application/problem+json", mime)
+	assert.True(t, jsonBody)
+
+	// Only examples are used for bodies which aren't JSON
+	op.Produces = []string{"text/plain"}
+	mime, jsonBody = st.mockMIME(op)
+	assert.Equal(t, "text/plain", mime)
+	assert.False(t, jsonBody)
+
+	resp := spec.NewResponse().WithSchema(spec.StringProperty())
+	body, err := st.mockBody(resp, mime, jsonBody)
+	require.NoError(t, err)
+	assert.Empty(t, body)
+
+	resp.AddExample("text/plain", "pong")
+	body, err = st.mockBody(resp, mime, jsonBody)
+	require.NoError(t, err)
+	assert.Equal(t, "pong", string(body))
+}
@@ -0,0 +1,701 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/loads"
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/swag"
+)
+
+// StatusRangesExtension is the Responses extension holding the responses
+// declared for a whole status class (e.g. "2XX"), keyed by the class.
+const StatusRangesExtension = "x-status-ranges"
+
+// RequiredHeaderExtension marks a response header which must be present.
+// Response headers are optional otherwise.
+const RequiredHeaderExtension = "x-required"
+
+// LoadJSONDocument loads a YAML or JSON document from a file or an URL, and
+// returns it as JSON.
+func LoadJSONDocument(path string) ([]byte, error) {
+	data, err := swag.LoadFromFileOrHTTP(path)
+	if err != nil {
+		return nil, err
+	}
+	if !swag.YAMLMatcher(path) {
+		return data, nil
+	}
+
+	yml, err := swag.BytesToYAMLDoc(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if data, err = swag.YAMLToJSON(yml); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return data, nil
+}
+
+// LoadSpec loads a Swagger 2.0 or an OpenAPI 3.x document from a file or an
+// URL. OpenAPI 3 documents are translated into their Swagger 2.0 equivalent
+// so they can be served by the same Proxy.
+func LoadSpec(path string) (*spec.Swagger, error) {
+	data, err := LoadJSONDocument(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var version struct {
+		OpenAPI string `json:"openapi"`
+	}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(version.OpenAPI, "3.") {
+		return ConvertOpenAPI3(data)
+	}
+
+	doc, err := loads.Spec(path)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Spec(), nil
+}
+
+type oas3Document struct {
+	OpenAPI    string                  `json:"openapi"`
+	Info       *spec.Info              `json:"info"`
+	Servers    []oas3Server            `json:"servers"`
+	Paths      map[string]oas3PathItem `json:"paths"`
+	Components oas3Components          `json:"components"`
+}
+
+type oas3Server struct {
+	URL       string `json:"url"`
+	Variables map[string]struct {
+		Default string `json:"default"`
+	} `json:"variables"`
+}
+
+type oas3Components struct {
+	Schemas       map[string]interface{}     `json:"schemas"`
+	Responses     map[string]oas3Response    `json:"responses"`
+	Parameters    map[string]oas3Parameter   `json:"parameters"`
+	RequestBodies map[string]oas3RequestBody `json:"requestBodies"`
+	Headers       map[string]oas3Header      `json:"headers"`
+}
+
+type oas3PathItem struct {
+	Parameters []oas3Parameter `json:"parameters"`
+	Get        *oas3Operation  `json:"get"`
+	Put        *oas3Operation  `json:"put"`
+	Post       *oas3Operation  `json:"post"`
+	Delete     *oas3Operation  `json:"delete"`
+	Options    *oas3Operation  `json:"options"`
+	Head       *oas3Operation  `json:"head"`
+	Patch      *oas3Operation  `json:"patch"`
+}
+
+type oas3Operation struct {
+	ID          string                  `json:"operationId"`
+	Tags        []string                `json:"tags"`
+	Summary     string                  `json:"summary"`
+	Description string                  `json:"description"`
+	Deprecated  bool                    `json:"deprecated"`
+	Parameters  []oas3Parameter         `json:"parameters"`
+	RequestBody *oas3RequestBody        `json:"requestBody"`
+	Responses   map[string]oas3Response `json:"responses"`
+}
+
+type oas3Parameter struct {
+	Ref             string                   `json:"$ref"`
+	Name            string                   `json:"name"`
+	In              string                   `json:"in"`
+	Description     string                   `json:"description"`
+	Required        bool                     `json:"required"`
+	AllowEmptyValue bool                     `json:"allowEmptyValue"`
+	Style           string                   `json:"style"`
+	Explode         *bool                    `json:"explode"`
+	Schema          interface{}              `json:"schema"`
+	Content         map[string]oas3MediaType `json:"content"`
+}
+
+type oas3RequestBody struct {
+	Ref         string                   `json:"$ref"`
+	Description string                   `json:"description"`
+	Required    bool                     `json:"required"`
+	Content     map[string]oas3MediaType `json:"content"`
+}
+
+type oas3Response struct {
+	Ref         string                   `json:"$ref"`
+	Description string                   `json:"description"`
+	Headers     map[string]oas3Header    `json:"headers"`
+	Content     map[string]oas3MediaType `json:"content"`
+}
+
+type oas3Header struct {
+	Ref         string      `json:"$ref"`
+	Description string      `json:"description"`
+	Required    bool        `json:"required"`
+	Schema      interface{} `json:"schema"`
+}
+
+type oas3MediaType struct {
+	Schema  interface{} `json:"schema"`
+	Example interface{} `json:"example"`
+}
+
+type oas3Converter struct {
+	doc     *oas3Document
+	swagger *spec.Swagger
+}
+
+// ConvertOpenAPI3 translates an OpenAPI 3.x JSON document into a Swagger 2.0
+// spec. Servers are mapped to the BasePath, requestBody to a body (or
+// formData) parameter, response content to the response schema and produces,
+// and nullable schemas to a type union with "null". Status class responses
+// such as "2XX" are kept under the StatusRangesExtension.
+func ConvertOpenAPI3(data []byte) (*spec.Swagger, error) {
+	var doc oas3Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	c := &oas3Converter{doc: &doc, swagger: &spec.Swagger{}}
+	return c.convert()
+}
+
+func (c *oas3Converter) convert() (*spec.Swagger, error) {
+	s := c.swagger
+	s.Swagger = "2.0"
+	s.Info = c.doc.Info
+
+	if len(c.doc.Servers) > 0 {
+		u, err := url.Parse(serverURL(c.doc.Servers[0]))
+		if err != nil {
+			return nil, err
+		}
+		s.Host = u.Host
+		s.BasePath = strings.TrimSuffix(u.Path, "/")
+		if u.Scheme != "" {
+			s.Schemes = []string{u.Scheme}
+		}
+	}
+
+	if len(c.doc.Components.Schemas) > 0 {
+		s.Definitions = make(spec.Definitions)
+		for name, v := range c.doc.Components.Schemas {
+			schema, err := oas3Schema(v)
+			if err != nil {
+				return nil, fmt.Errorf("components.schemas.%s: %s", name, err)
+			}
+			s.Definitions[name] = *schema
+		}
+	}
+
+	s.Paths = &spec.Paths{Paths: make(map[string]spec.PathItem)}
+	for path, item := range c.doc.Paths {
+		pathItem, err := c.pathItem(&item)
+		if err != nil {
+			return nil, fmt.Errorf("paths.%s: %s", path, err)
+		}
+		s.Paths.Paths[path] = *pathItem
+	}
+
+	return s, nil
+}
+
+func (c *oas3Converter) pathItem(item *oas3PathItem) (*spec.PathItem, error) {
+	var pathItem spec.PathItem
+
+	params, err := c.parameters(item.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	pathItem.Parameters = params
+
+	for _, entry := range []struct {
+		src *oas3Operation
+		dst **spec.Operation
+	}{
+		{item.Get, &pathItem.Get},
+		{item.Put, &pathItem.Put},
+		{item.Post, &pathItem.Post},
+		{item.Delete, &pathItem.Delete},
+		{item.Options, &pathItem.Options},
+		{item.Head, &pathItem.Head},
+		{item.Patch, &pathItem.Patch},
+	} {
+		if entry.src == nil {
+			continue
+		}
+		op, err := c.operation(entry.src)
+		if err != nil {
+			return nil, err
+		}
+		*entry.dst = op
+	}
+
+	return &pathItem, nil
+}
+
+func (c *oas3Converter) operation(src *oas3Operation) (*spec.Operation, error) {
+	op := spec.NewOperation(src.ID)
+	op.Tags = src.Tags
+	op.Summary = src.Summary
+	op.Description = src.Description
+	op.Deprecated = src.Deprecated
+
+	params, err := c.parameters(src.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	op.Parameters = params
+
+	if src.RequestBody != nil {
+		params, consumes, err := c.requestBody(src.RequestBody)
+		if err != nil {
+			return nil, fmt.Errorf("%s requestBody: %s", src.ID, err)
+		}
+		op.Parameters = append(op.Parameters, params...)
+		op.Consumes = consumes
+	}
+
+	op.Responses = &spec.Responses{}
+	produces := make(map[string]struct{})
+	ranges := make(map[string]spec.Response)
+	for code, src := range src.Responses {
+		resp, err := c.response(&src, produces)
+		if err != nil {
+			return nil, fmt.Errorf("%s responses.%s: %s", op.ID, code, err)
+		}
+
+		if code == "default" {
+			op.Responses.Default = resp
+			continue
+		}
+
+		if status, err := strconv.Atoi(code); err == nil {
+			if op.Responses.StatusCodeResponses == nil {
+				op.Responses.StatusCodeResponses = make(map[int]spec.Response)
+			}
+			op.Responses.StatusCodeResponses[status] = *resp
+			continue
+		}
+
+		code = strings.ToUpper(code)
+		if len(code) != 3 || code[1:] != "XX" || code[0] < '1' || code[0] > '5' {
+			return nil, fmt.Errorf("%s responses: invalid status code %q", op.ID, code)
+		}
+		ranges[code] = *resp
+	}
+
+	if len(ranges) > 0 {
+		op.Responses.AddExtension(StatusRangesExtension, ranges)
+	}
+	op.Produces = sortedKeys(produces)
+
+	return op, nil
+}
+
+func (c *oas3Converter) parameters(src []oas3Parameter) ([]spec.Parameter, error) {
+	var params []spec.Parameter
+	for _, p := range src {
+		if p.Ref != "" {
+			name := strings.TrimPrefix(p.Ref, "#/components/parameters/")
+			ref, ok := c.doc.Components.Parameters[name]
+			if !ok {
+				return nil, fmt.Errorf("unresolved reference %q", p.Ref)
+			}
+			p = ref
+		}
+
+		param := spec.Parameter{}
+		param.Name = p.Name
+		param.In = p.In
+		param.Description = p.Description
+		param.Required = p.Required || p.In == "path"
+		param.AllowEmptyValue = p.AllowEmptyValue
+
+		if p.Schema != nil {
+			schema, err := oas3Schema(p.Schema)
+			if err != nil {
+				return nil, fmt.Errorf("parameter %s: %s", p.Name, err)
+			}
+			param.SimpleSchema, param.CommonValidations = c.simpleSchema(schema)
+		} else {
+			// Parameters described with `content` are serialized as a
+			// whole, their value is not checked.
+			param.Type = "string"
+		}
+
+		if param.Type == "array" {
+			param.CollectionFormat = collectionFormat(&p)
+		}
+
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+// collectionFormat maps the OpenAPI 3 style/explode serialization to the
+// closest Swagger 2.0 collectionFormat.
+func collectionFormat(p *oas3Parameter) string {
+	style := p.Style
+	if style == "" {
+		style = "simple"
+		if p.In == "query" || p.In == "cookie" {
+			style = "form"
+		}
+	}
+
+	explode := style == "form"
+	if p.Explode != nil {
+		explode = *p.Explode
+	}
+
+	switch style {
+	case "form":
+		if explode {
+			return "multi"
+		}
+	case "spaceDelimited":
+		return "ssv"
+	case "pipeDelimited":
+		return "pipes"
+	}
+	return "csv"
+}
+
+func (c *oas3Converter) requestBody(body *oas3RequestBody) ([]spec.Parameter, []string, error) {
+	if body.Ref != "" {
+		name := strings.TrimPrefix(body.Ref, "#/components/requestBodies/")
+		ref, ok := c.doc.Components.RequestBodies[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unresolved reference %q", body.Ref)
+		}
+		body = &ref
+	}
+
+	consumes := make(map[string]struct{})
+	for mime := range body.Content {
+		consumes[mime] = struct{}{}
+	}
+
+	if mt, ok := formMediaType(body.Content); ok {
+		params, err := c.formParameters(mt, body.Required)
+		return params, sortedKeys(consumes), err
+	}
+
+	param := spec.BodyParam("body", nil)
+	param.Description = body.Description
+	param.Required = body.Required
+	if mt, ok := jsonMediaType(body.Content); ok && mt.Schema != nil {
+		schema, err := oas3Schema(mt.Schema)
+		if err != nil {
+			return nil, nil, err
+		}
+		param.Schema = schema
+	}
+
+	return []spec.Parameter{*param}, sortedKeys(consumes), nil
+}
+
+// formParameters turns the properties of a form body schema into formData
+// parameters.
+func (c *oas3Converter) formParameters(mt *oas3MediaType, required bool) ([]spec.Parameter, error) {
+	schema, err := oas3Schema(mt.Schema)
+	if err != nil {
+		return nil, err
+	}
+	schema = c.definition(schema)
+
+	requiredProps := make(map[string]bool)
+	for _, name := range schema.Required {
+		requiredProps[name] = required
+	}
+
+	var names []string
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var params []spec.Parameter
+	for _, name := range names {
+		prop := schema.Properties[name]
+		param := spec.FormDataParam(name)
+		param.Description = prop.Description
+		param.Required = requiredProps[name]
+		param.SimpleSchema, param.CommonValidations = c.simpleSchema(&prop)
+		if param.Type == "string" && prop.Format == "binary" {
+			param.Type = "file"
+			param.Format = ""
+		}
+		if param.Type == "array" {
+			param.CollectionFormat = "multi"
+		}
+		params = append(params, *param)
+	}
+	return params, nil
+}
+
+func (c *oas3Converter) response(src *oas3Response, produces map[string]struct{}) (*spec.Response, error) {
+	if src.Ref != "" {
+		name := strings.TrimPrefix(src.Ref, "#/components/responses/")
+		ref, ok := c.doc.Components.Responses[name]
+		if !ok {
+			return nil, fmt.Errorf("unresolved reference %q", src.Ref)
+		}
+		src = &ref
+	}
+
+	resp := spec.NewResponse().WithDescription(src.Description)
+	for mime := range src.Content {
+		produces[mime] = struct{}{}
+	}
+
+	if mt, ok := jsonMediaType(src.Content); ok && mt.Schema != nil {
+		schema, err := oas3Schema(mt.Schema)
+		if err != nil {
+			return nil, err
+		}
+		resp.Schema = schema
+		if mt.Example != nil {
+			resp.AddExample("application/json", mt.Example)
+		}
+	}
+
+	for name, h := range src.Headers {
+		if h.Ref != "" {
+			ref, ok := c.doc.Components.Headers[strings.TrimPrefix(h.Ref, "#/components/headers/")]
+			if !ok {
+				return nil, fmt.Errorf("unresolved reference %q", h.Ref)
+			}
+			h = ref
+		}
+
+		header := spec.ResponseHeader()
+		header.Description = h.Description
+		if h.Schema != nil {
+			schema, err := oas3Schema(h.Schema)
+			if err != nil {
+				return nil, fmt.Errorf("header %s: %s", name, err)
+			}
+			header.SimpleSchema, header.CommonValidations = c.simpleSchema(schema)
+		}
+		if header.Type == "array" {
+			header.CollectionFormat = "csv"
+		}
+		if h.Required {
+			header.AddExtension(RequiredHeaderExtension, true)
+		}
+		resp.AddHeader(name, header)
+	}
+
+	return resp, nil
+}
+
+// simpleSchema extracts the subset of a JSON schema that can be expressed by
+// non-body parameters, headers and items.
+func (c *oas3Converter) simpleSchema(schema *spec.Schema) (spec.SimpleSchema, spec.CommonValidations) {
+	schema = c.definition(schema)
+
+	simple := spec.SimpleSchema{
+		Format:  schema.Format,
+		Default: schema.Default,
+	}
+	for _, t := range schema.Type {
+		if t != "null" {
+			simple.Type = t
+			break
+		}
+	}
+
+	if simple.Type == "array" && schema.Items != nil && schema.Items.Schema != nil {
+		items := spec.NewItems()
+		items.SimpleSchema, items.CommonValidations = c.simpleSchema(schema.Items.Schema)
+		if items.Type == "array" {
+			items.CollectionFormat = "csv"
+		}
+		simple.Items = items
+	}
+
+	return simple, spec.CommonValidations{
+		Maximum:          schema.Maximum,
+		ExclusiveMaximum: schema.ExclusiveMaximum,
+		Minimum:          schema.Minimum,
+		ExclusiveMinimum: schema.ExclusiveMinimum,
+		MaxLength:        schema.MaxLength,
+		MinLength:        schema.MinLength,
+		Pattern:          schema.Pattern,
+		MaxItems:         schema.MaxItems,
+		MinItems:         schema.MinItems,
+		UniqueItems:      schema.UniqueItems,
+		MultipleOf:       schema.MultipleOf,
+		Enum:             schema.Enum,
+	}
+}
+
+// definition returns the converted definition a schema refers to. The
+// components have all been turned into #/definitions by then, but the spec
+// isn't complete yet, so only those are looked up.
+func (c *oas3Converter) definition(schema *spec.Schema) *spec.Schema {
+	for i := 0; i < 32 && schema.Ref.String() != ""; i++ {
+		name := strings.TrimPrefix(schema.Ref.String(), "#/definitions/")
+		def, ok := c.swagger.Definitions[name]
+		if !ok {
+			break
+		}
+		schema = &def
+	}
+	return schema
+}
+
+// oas3Schema converts an OpenAPI 3 schema object into a spec.Schema.
+func oas3Schema(v interface{}) (*spec.Schema, error) {
+	data, err := json.Marshal(normalizeSchema(v))
+	if err != nil {
+		return nil, err
+	}
+
+	var schema spec.Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// normalizeSchema rewrites the OpenAPI 3 keywords which have no direct
+// equivalent in the Swagger 2.0 schema object.
+func normalizeSchema(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeSchema(v[i])
+		}
+		return v
+	case map[string]interface{}:
+		m := v
+		if ref, ok := m["$ref"].(string); ok {
+			m["$ref"] = strings.Replace(ref, "#/components/schemas/", "#/definitions/", 1)
+		}
+
+		if nullable, _ := m["nullable"].(bool); nullable {
+			switch t := m["type"].(type) {
+			case string:
+				m["type"] = []interface{}{t, "null"}
+			case []interface{}:
+				m["type"] = append(t, "null")
+			}
+		}
+		delete(m, "nullable")
+
+		if d, ok := m["discriminator"].(map[string]interface{}); ok {
+			m["discriminator"] = d["propertyName"]
+		}
+
+		// OpenAPI 3.1 uses numeric exclusive bounds
+		for key, bound := range map[string]string{"exclusiveMinimum": "minimum", "exclusiveMaximum": "maximum"} {
+			if n, ok := m[key].(float64); ok {
+				m[bound] = n
+				m[key] = true
+			}
+		}
+
+		if c, ok := m["const"]; ok {
+			m["enum"] = []interface{}{c}
+			delete(m, "const")
+		}
+
+		for _, key := range []string{"items", "not", "additionalProperties", "additionalItems", "allOf", "oneOf", "anyOf"} {
+			if s, ok := m[key]; ok {
+				m[key] = normalizeSchema(s)
+			}
+		}
+
+		for _, key := range []string{"properties", "patternProperties"} {
+			if props, ok := m[key].(map[string]interface{}); ok {
+				for name, s := range props {
+					props[name] = normalizeSchema(s)
+				}
+			}
+		}
+		return m
+	}
+	return v
+}
+
+func serverURL(s oas3Server) string {
+	u := s.URL
+	for name, v := range s.Variables {
+		u = strings.Replace(u, "{"+name+"}", v.Default, -1)
+	}
+	return u
+}
+
+// jsonMediaType returns the JSON media type of a content map, falling back
+// to the first one.
+func jsonMediaType(content map[string]oas3MediaType) (*oas3MediaType, bool) {
+	keys := mediaTypes(content)
+	for _, key := range keys {
+		if isJSON(key) {
+			mt := content[key]
+			return &mt, true
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, false
+	}
+	mt := content[keys[0]]
+	return &mt, true
+}
+
+// formMediaType returns the form media type of a content map, unless it can
+// also be sent as JSON.
+func formMediaType(content map[string]oas3MediaType) (*oas3MediaType, bool) {
+	for key := range content {
+		if isJSON(key) {
+			return nil, false
+		}
+	}
+
+	for _, key := range []string{"application/x-www-form-urlencoded", "multipart/form-data"} {
+		if mt, ok := content[key]; ok && mt.Schema != nil {
+			return &mt, true
+		}
+	}
+	return nil, false
+}
+
+func isJSON(mime string) bool {
+	mime = strings.TrimSpace(strings.Split(mime, ";")[0])
+	return mime == "application/json" || strings.HasSuffix(mime, "+json")
+}
+
+func mediaTypes(content map[string]oas3MediaType) []string {
+	var keys []string
+	for key := range content {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-openapi/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPI3(t *testing.T) {
+	swagger, err := LoadSpec("./fixtures/petstore-oas3.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "/v1", swagger.BasePath)
+
+	app, err := New(swagger, nil)
+	require.NoError(t, err)
+
+	t.Run("Routes", func(t *testing.T) {
+		var ids []string
+		WalkOps(swagger, func(path, meth string, op *spec.Operation) {
+			ids = append(ids, op.ID)
+		})
+		sort.Strings(ids)
+		assert.Equal(t, []string{"createPets", "listPets", "showPetById"}, ids)
+	})
+
+	t.Run("Responses", func(t *testing.T) {
+		op := swagger.Paths.Paths["/pets/{petId}"].Get
+		assert.Equal(t, []string{"application/json"}, op.Produces)
+
+		resp := &testResponse{status: 200, header: http.Header{}}
+		resp.Header().Set("Content-Type", "application/json")
+
+		resp.body = []byte(`{"id": 1, "name": "Tom", "tag": null, "kind": "cat"}`)
+		assert.NoError(t, app.Validate(resp, op))
+
+		resp.body = []byte(`{"id": 1, "name": "Tom", "kind": true}`)
+		assert.Error(t, app.Validate(resp, op), "kind matches none of oneOf")
+
+		resp.body = []byte(`{"id": 1, "name": null}`)
+		assert.Error(t, app.Validate(resp, op), "name is not nullable")
+	})
+
+	t.Run("Headers", func(t *testing.T) {
+		op := swagger.Paths.Paths["/pets"].Get
+		resp := &testResponse{status: 200, header: http.Header{}}
+
+		assert.Error(t, app.ValidateHeaders(resp, op), "x-total is required")
+
+		resp.Header().Set("X-Total", "-1")
+		assert.Error(t, app.ValidateHeaders(resp, op), "x-total is positive")
+
+		resp.Header().Set("X-Total", "2")
+		assert.NoError(t, app.ValidateHeaders(resp, op), "x-next is optional")
+	})
+
+	t.Run("StatusRanges", func(t *testing.T) {
+		op := swagger.Paths.Paths["/pets"].Get
+		ranges, ok := op.Responses.Extensions[StatusRangesExtension].(map[string]spec.Response)
+		require.True(t, ok)
+		assert.Contains(t, ranges, "4XX")
+
+		resp := &testResponse{status: 404, header: http.Header{}, body: []byte(`{"code": 404}`)}
+		resp.Header().Set("Content-Type", "application/json")
+		assert.Error(t, app.Validate(resp, op), "4XX requires a message")
+
+		resp.body = []byte(`{"code": 404, "message": "Not Found"}`)
+		assert.NoError(t, app.Validate(resp, op))
+
+		resp.status = 503
+		assert.NoError(t, app.Validate(resp, op), "503 falls back to default")
+	})
+
+	t.Run("Requests", func(t *testing.T) {
+		list := swagger.Paths.Paths["/pets"].Get
+		req := httptest.NewRequest("GET", "/v1/pets?limit=10", nil)
+		assert.NoError(t, app.ValidateRequest(req, nil, list))
+
+		req = httptest.NewRequest("GET", "/v1/pets?limit=1000", nil)
+		assert.Error(t, app.ValidateRequest(req, nil, list), "limit is above maximum")
+
+		create := swagger.Paths.Paths["/pets"].Post
+		req = httptest.NewRequest("POST", "/v1/pets", strings.NewReader(`{"name": "Tom"}`))
+		assert.Error(t, app.ValidateRequest(req, nil, create), "id is required")
+
+		show := swagger.Paths.Paths["/pets/{petId}"].Get
+		req = httptest.NewRequest("GET", "/v1/pets/x", nil)
+		assert.Error(t, app.ValidateRequest(req, map[string]string{"petId": "x"}, show))
+	})
+}
@@ -0,0 +1,35 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type       string      `json:"type"`
+	Title      string      `json:"title"`
+	Status     int         `json:"status"`
+	Detail     string      `json:"detail,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// writeProblem replaces whatever was going to be sent on w with a problem
+// describing err.
+func writeProblem(w http.ResponseWriter, status int, title string, err error) error {
+	for key := range w.Header() {
+		w.Header().Del(key)
+	}
+
+	problem := Problem{
+		Type:       "about:blank",
+		Title:      title,
+		Status:     status,
+		Detail:     err.Error(),
+		Violations: Violations(err),
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(problem)
+}
@@ -3,32 +3,116 @@ package proxy
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-openapi/errors"
 	"github.com/go-openapi/spec"
 	"github.com/go-openapi/strfmt"
-	"github.com/go-openapi/swag"
 	"github.com/go-openapi/validate"
 	"github.com/gorilla/mux"
 )
 
+// DefaultMaxBodySize is the maximum size of the response bodies held for
+// validation unless WithMaxBodySize is used.
+const DefaultMaxBodySize = 10 << 20
+
+// StreamExtension marks an operation whose responses are streamed to the
+// client without holding nor validating their bodies. Operations responding
+// with a file (type: file, or type: string with format: binary) are streamed
+// as well.
+const StreamExtension = "x-stream"
+
 type Proxy struct {
 	// Opts
 	target  string
 	verbose bool
+	output  io.Writer
+	enforce bool
+	mock    bool
+
+	mockOperations    map[string]struct{}
+	mockTags          map[string]struct{}
+	mockUnimplemented bool
 
-	router       *mux.Router
-	routes       map[*mux.Route]*spec.Operation
 	reverseProxy http.Handler
 
 	reporter Reporter
 
-	doc               interface{} // This is useful for validate (TODO: find a better way)
-	spec              *spec.Swagger
-	pendingOperations map[*spec.Operation]struct{}
+	mu    sync.RWMutex
+	state *state
+
+	maxBodySize int64
+
+	rulesMu sync.RWMutex
+	rules   *Rules
+
+	stats   *stats
+	metrics *metrics
+	har     *HARRecorder
+}
+
+// state holds everything derived from the spec. It is never modified once
+// built but for the pending operations, SetSpec and Reset replace it as a
+// whole so that requests in flight keep a consistent view.
+type state struct {
+	spec   *spec.Swagger
+	doc    interface{} // This is useful for validate (TODO: find a better way)
+	router *mux.Router
+	routes map[*mux.Route]*spec.Operation
+	params map[*spec.Operation][]spec.Parameter
+
+	// Bodies bigger than this once decoded aren't validated
+	maxBodySize int64
+
+	// operations in registration order
+	operations []operation
+	pending    *pendingOperations
+}
+
+type operation struct {
+	method string
+	path   string
+	op     *spec.Operation
+}
+
+// pendingOperations are the operations of a state not exercised yet.
+type pendingOperations struct {
+	mu  sync.Mutex
+	ops map[*spec.Operation]struct{}
+}
+
+func newPendingOperations(ops []operation) *pendingOperations {
+	p := &pendingOperations{ops: make(map[*spec.Operation]struct{})}
+	for _, op := range ops {
+		p.ops[op.op] = struct{}{}
+	}
+	return p
+}
+
+func (p *pendingOperations) list() []*spec.Operation {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var ops []*spec.Operation
+	for op := range p.ops {
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+func (p *pendingOperations) executed(op *spec.Operation) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.ops, op)
 }
 
 type ProxyOpt func(*Proxy)
@@ -36,16 +120,73 @@ type ProxyOpt func(*Proxy)
 func WithTarget(target string) ProxyOpt { return func(proxy *Proxy) { proxy.target = target } }
 func WithVerbose(v bool) ProxyOpt       { return func(proxy *Proxy) { proxy.verbose = v } }
 
-func New(s *spec.Swagger, reporter Reporter, opts ...ProxyOpt) (*Proxy, error) {
-	proxy := &Proxy{
-		target:   "http://localhost:8080",
-		router:   mux.NewRouter(),
-		routes:   make(map[*mux.Route]*spec.Operation),
-		reporter: reporter,
+// WithOutput makes the Proxy print its verbose output to w instead of
+// stdout.
+func WithOutput(w io.Writer) ProxyOpt { return func(proxy *Proxy) { proxy.output = w } }
+
+// WithEnforce makes the Proxy replace the responses which don't conform to
+// the spec with a 502 problem+json listing the violations.
+func WithEnforce(v bool) ProxyOpt { return func(proxy *Proxy) { proxy.enforce = v } }
+
+// WithMock makes the Proxy answer with responses synthesized from the spec
+// instead of forwarding the requests to the target.
+func WithMock(v bool) ProxyOpt { return func(proxy *Proxy) { proxy.mock = v } }
+
+// WithMockOperations mocks the operations with the given operationIds while
+// the rest are forwarded to the target.
+func WithMockOperations(ids ...string) ProxyOpt {
+	return func(proxy *Proxy) {
+		for _, id := range ids {
+			proxy.mockOperations[id] = struct{}{}
+		}
 	}
+}
 
-	if err := proxy.SetSpec(s); err != nil {
-		return nil, err
+// WithMockTags mocks the operations tagged with any of tags while the rest
+// are forwarded to the target.
+func WithMockTags(tags ...string) ProxyOpt {
+	return func(proxy *Proxy) {
+		for _, tag := range tags {
+			proxy.mockTags[tag] = struct{}{}
+		}
+	}
+}
+
+// WithMockUnimplemented mocks the responses of the operations the target
+// doesn't implement yet, that is when it answers with a 501 or with a 404
+// not defined by the operation.
+func WithMockUnimplemented(v bool) ProxyOpt {
+	return func(proxy *Proxy) { proxy.mockUnimplemented = v }
+}
+
+// WithMaxBodySize sets the maximum size of the request and response bodies
+// held for validation, bigger ones are forwarded but not validated. 0 means
+// no limit.
+func WithMaxBodySize(n int64) ProxyOpt {
+	return func(proxy *Proxy) { proxy.maxBodySize = n }
+}
+
+// WithRules applies rules to the violations before they are reported.
+func WithRules(rules *Rules) ProxyOpt {
+	return func(proxy *Proxy) { proxy.rules = rules }
+}
+
+// WithHARRecorder records every exchange, along with its validation outcome,
+// to r.
+func WithHARRecorder(r *HARRecorder) ProxyOpt {
+	return func(proxy *Proxy) { proxy.har = r }
+}
+
+func New(s *spec.Swagger, reporter Reporter, opts ...ProxyOpt) (*Proxy, error) {
+	proxy := &Proxy{
+		target:         "http://localhost:8080",
+		output:         os.Stdout,
+		maxBodySize:    DefaultMaxBodySize,
+		reporter:       reporter,
+		mockOperations: make(map[string]struct{}),
+		mockTags:       make(map[string]struct{}),
+		stats:          newStats(),
+		metrics:        newMetrics(),
 	}
 
 	for _, opt := range opts {
@@ -58,12 +199,15 @@ func New(s *spec.Swagger, reporter Reporter, opts ...ProxyOpt) (*Proxy, error) {
 	}
 	proxy.reverseProxy = httputil.NewSingleHostReverseProxy(rpURL)
 
-	proxy.router.NotFoundHandler = http.HandlerFunc(proxy.notFound)
-	proxy.registerPaths()
+	if err := proxy.SetSpec(s); err != nil {
+		return nil, err
+	}
 
 	return proxy, nil
 }
 
+// SetSpec replaces the spec being served. It is safe to call while the
+// Proxy is handling requests, pending operations are reset.
 func (proxy *Proxy) SetSpec(spec *spec.Swagger) error {
 	// validate.NewSchemaValidator requires the spec as an interface{}
 	// That's why we Unmarshal(Marshal()) the document
@@ -77,41 +221,205 @@ func (proxy *Proxy) SetSpec(spec *spec.Swagger) error {
 		return err
 	}
 
-	proxy.doc = doc
-	proxy.spec = spec
+	st := &state{spec: spec, doc: doc, maxBodySize: proxy.maxBodySize}
+	proxy.registerPaths(st)
+	st.pending = newPendingOperations(st.operations)
 
-	proxy.registerPaths()
+	proxy.mu.Lock()
+	proxy.state = st
+	proxy.mu.Unlock()
 	return nil
 }
 
+// SetRules replaces the rules applied to the violations. It is safe to call
+// while the Proxy is handling requests.
+func (proxy *Proxy) SetRules(rules *Rules) {
+	proxy.rulesMu.Lock()
+	proxy.rules = rules
+	proxy.rulesMu.Unlock()
+}
+
+// Rules returns the rules applied to the violations.
+func (proxy *Proxy) Rules() *Rules {
+	proxy.rulesMu.RLock()
+	defer proxy.rulesMu.RUnlock()
+	return proxy.rules
+}
+
+func (proxy *Proxy) current() *state {
+	proxy.mu.RLock()
+	defer proxy.mu.RUnlock()
+	return proxy.state
+}
+
+// Router returns an http.Handler that routes requests using the current
+// spec.
 func (proxy *Proxy) Router() http.Handler {
-	return proxy.router
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		proxy.current().router.ServeHTTP(w, req)
+	})
 }
 
 func (proxy *Proxy) Target() string {
 	return proxy.target
 }
 
-func (proxy *Proxy) registerPaths() {
-	proxy.pendingOperations = make(map[*spec.Operation]struct{})
-	base := proxy.spec.BasePath
+func (proxy *Proxy) registerPaths(st *state) {
+	base := st.spec.BasePath
+
+	st.router = mux.NewRouter()
+	st.router.NotFoundHandler = http.HandlerFunc(proxy.notFound)
+	st.routes = make(map[*mux.Route]*spec.Operation)
+	st.params = make(map[*spec.Operation][]spec.Parameter)
 
-	router := mux.NewRouter()
-	WalkOps(proxy.spec, func(path, method string, op *spec.Operation) {
+	// Routes are matched in registration order, so literal segments are
+	// registered before templated ones (/pet/findByStatus before /pet/{petId})
+	WalkOps(st.spec, func(path, method string, op *spec.Operation) {
+		st.operations = append(st.operations, operation{method: method, path: path, op: op})
+	})
+	sort.Slice(st.operations, func(i, j int) bool {
+		a, b := st.operations[i], st.operations[j]
+		if a.path != b.path {
+			return pathOrder(a.path) < pathOrder(b.path)
+		}
+		return a.method < b.method
+	})
+
+	handler := proxy.newHandler()
+	for _, o := range st.operations {
+		path, method, op := o.path, o.method, o.op
 		newPath := base + path
+		mocked := proxy.mocked(op)
 		if proxy.verbose {
-			fmt.Printf("Register %s %s\n", method, newPath)
+			if mocked {
+				fmt.Fprintf(proxy.output, "Register %s %s (mock)\n", method, newPath)
+			} else {
+				fmt.Fprintf(proxy.output, "Register %s %s\n", method, newPath)
+			}
 		}
-		route := router.Handle(newPath, proxy.newHandler()).Methods(method)
-		proxy.routes[route] = op
-		proxy.pendingOperations[op] = struct{}{}
-	})
-	*proxy.router = *router
+
+		h := handler
+		switch {
+		case mocked:
+			h = proxy.Handler(st.mockHandler(op))
+		case proxy.mockUnimplemented:
+			h = proxy.Handler(proxy.fallbackHandler(op, st.mockHandler(op)))
+		}
+		route := st.router.Handle(newPath, h).Methods(method)
+		st.routes[route] = op
+		item := st.spec.Paths.Paths[path]
+		st.params[op] = operationParams(st.spec, &item, op)
+	}
+}
+
+func pathOrder(path string) string {
+	return strings.Replace(path, "{", "\xff", -1)
+}
+
+func (proxy *Proxy) mocked(op *spec.Operation) bool {
+	if proxy.mock {
+		return true
+	}
+
+	if _, ok := proxy.mockOperations[op.ID]; ok {
+		return true
+	}
+
+	for _, tag := range op.Tags {
+		if _, ok := proxy.mockTags[tag]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fallbackHandler forwards the request to the target, and answers with mock
+// instead if the target doesn't implement op.
+func (proxy *Proxy) fallbackHandler(op *spec.Operation, mock http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		fw := &fallbackWriter{ResponseWriter: w, op: op}
+		proxy.reverseProxy.ServeHTTP(fw, req)
+
+		if fw.discard {
+			mock.ServeHTTP(w, req)
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+// fallbackWriter decides on the status whether the response of the target
+// goes through, or is discarded for the operation is unimplemented.
+type fallbackWriter struct {
+	http.ResponseWriter
+	op *spec.Operation
+
+	wroteHeader bool
+	discard     bool
+}
+
+func (w *fallbackWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if w.discard = unimplemented(w.op, status); w.discard {
+		for key := range w.Header() {
+			w.Header().Del(key)
+		}
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *fallbackWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.discard {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *fallbackWriter) Flush() {
+	if w.wroteHeader && !w.discard {
+		flush(w.ResponseWriter)
+	}
+}
+
+// Unwrap lets http.ResponseController reach the other optional interfaces.
+func (w *fallbackWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func unimplemented(op *spec.Operation, status int) bool {
+	switch status {
+	case http.StatusNotImplemented:
+		return true
+	case http.StatusNotFound:
+		if op.Responses == nil {
+			return true
+		}
+		_, ok := op.Responses.StatusCodeResponses[status]
+		return !ok
+	}
+	return false
 }
 
 func (proxy *Proxy) notFound(w http.ResponseWriter, req *http.Request) {
-	proxy.reporter.Warning(req, "Route not defined on the Spec")
-	proxy.reverseProxy.ServeHTTP(w, req)
+	req, ex := proxy.newExchange(req, nil)
+
+	wr := &WriterRecorder{ResponseWriter: w, Limit: proxy.maxBodySize}
+	start := time.Now()
+	if proxy.mock {
+		http.NotFound(wr, req)
+	} else {
+		proxy.reverseProxy.ServeHTTP(wr, req)
+	}
+	ex.Duration = time.Since(start)
+	ex.Status = wr.Status()
+	ex.response = wr
+
+	proxy.warning(req, "Route not defined on the Spec")
 }
 
 func (proxy *Proxy) newHandler() http.Handler {
@@ -119,64 +427,197 @@ func (proxy *Proxy) newHandler() http.Handler {
 }
 func (proxy *Proxy) Handler(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, req *http.Request) {
-		wr := &WriterRecorder{ResponseWriter: w}
-		next.ServeHTTP(wr, req)
+		st := proxy.current()
 
 		var match mux.RouteMatch
-		proxy.router.Match(req, &match)
-		op := proxy.routes[match.Route]
+		st.router.Match(req, &match)
+		op := st.routes[match.Route]
+
+		req, ex := proxy.newExchange(req, op)
+
+		if op != nil {
+			ex.Path = st.pathTemplate(match.Route)
+			if err := st.validateRequest(req, match.Vars, op); err != nil {
+				if nv, ok := err.(notValidated); ok {
+					proxy.requestWarning(req, nv.Error())
+				} else {
+					proxy.requestError(req, err)
+				}
+			}
+		}
+
+		limit := proxy.maxBodySize
+		stream := op != nil && streamOnly(op)
+		if stream {
+			limit = -1
+		}
+
+		// Event streams are validated event by event, as they go through.
+		// As a stream might never end, the invalid events are reported in
+		// batches while it goes on, the last one along with the response.
+		var wr recorder
+		var events *sseStream
+		var eventErrs error
+		var badEvents int
+		var batchStart time.Time
+		eventsReported := false
+		if op != nil {
+			events = &sseStream{fn: func(i int, ev sseEvent) {
+				if err := st.validateEvent(op, wr.Status(), i, ev); err != nil {
+					if badEvents == 0 {
+						batchStart = time.Now()
+					}
+					eventErrs = appendError(eventErrs, err)
+					badEvents++
+				}
+
+				if badEvents >= maxEventBatch || badEvents > 0 && time.Since(batchStart) >= eventBatchInterval {
+					ex.Status = wr.Status()
+					proxy.error(req, eventErrs)
+					eventErrs, badEvents, eventsReported = nil, 0, true
+				}
+			}}
+		}
+
+		wr = &WriterRecorder{ResponseWriter: w, Limit: limit, events: events}
+		buffered := proxy.enforce && op != nil
+		if buffered {
+			wr = &BufferedRecorder{ResponseWriter: w, Limit: limit, events: events}
+		}
+		ex.response = wr
+		start := time.Now()
+		next.ServeHTTP(wr, req)
+		ex.Duration = time.Since(start)
+		ex.Status = wr.Status()
 
 		if match.Handler == nil || op == nil {
-			proxy.reporter.Warning(req, "Route not defined on the Spec")
+			proxy.warning(req, "Route not defined on the Spec")
 			// Route hasn't been registered on the muxer
 			return
 		}
-		proxy.operationExecuted(op)
+		st.pending.executed(op)
+
+		if wr.Hijacked() {
+			proxy.warning(req, "Connection hijacked, not validated")
+			return
+		}
 
-		if err := proxy.Validate(wr, op); err != nil {
-			proxy.reporter.Error(req, err)
-		} else {
-			proxy.reporter.Success(req)
+		err := st.validate(wr, op)
+		nv, skipped := err.(notValidated)
+		if skipped {
+			err = nil
+		}
+		err = appendError(err, validateAccept(req, wr))
+		err = appendError(err, eventErrs)
+		switch {
+		case err != nil:
+			// Only the violations left by the rules replace the response
+			err = proxy.error(req, err)
+		case skipped:
+			proxy.warning(req, nv.Error())
+		case wr.Truncated() && !stream:
+			proxy.warning(req, errBodyTooLarge.Error())
+		case eventsReported:
+			// The exchange already has an outcome
+		default:
+			proxy.success(req)
+		}
+
+		if br, ok := wr.(*BufferedRecorder); ok && !br.passthrough {
+			if err != nil {
+				writeProblem(w, http.StatusBadGateway, "Response does not conform to the spec", err)
+				return
+			}
+			br.Send()
 		}
 	}
 	return http.HandlerFunc(fn)
 }
 
+type recorder interface {
+	http.ResponseWriter
+	Response
+	Truncated() bool
+	Hijacked() bool
+}
+
+func (st *state) pathTemplate(route *mux.Route) string {
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(tpl, st.spec.BasePath)
+}
+
 type validatorFunc func(Response, *spec.Operation) error
 
 func (proxy *Proxy) Validate(resp Response, op *spec.Operation) error {
-	if _, ok := op.Responses.StatusCodeResponses[resp.Status()]; !ok {
+	return proxy.current().validate(resp, op)
+}
+
+func (proxy *Proxy) ValidateMIME(resp Response, op *spec.Operation) error {
+	return proxy.current().validateMIME(resp, op)
+}
+
+func (proxy *Proxy) ValidateHeaders(resp Response, op *spec.Operation) error {
+	return proxy.current().validateHeaders(resp, op)
+}
+
+func (proxy *Proxy) ValidateBody(resp Response, op *spec.Operation) error {
+	return proxy.current().validateBody(resp, op)
+}
+
+func (st *state) validate(resp Response, op *spec.Operation) error {
+	if _, ok := operationResponse(op, resp.Status()); !ok {
 		return fmt.Errorf("Server Status %d not defined by the spec", resp.Status())
 	}
 
 	var validators = []validatorFunc{
-		proxy.ValidateMIME,
-		proxy.ValidateHeaders,
-		proxy.ValidateBody,
+		st.validateMIME,
+		st.validateHeaders,
+		st.validateBody,
 	}
 
 	var errs []error
+	var skipped error
 	for _, v := range validators {
-		if err := v(resp, op); err != nil {
-			if cErr, ok := err.(*errors.CompositeError); ok {
-				errs = append(errs, cErr.Errors...)
-			} else {
-				errs = append(errs, err)
-			}
+		err := v(resp, op)
+		if cErr, ok := err.(*errors.CompositeError); ok {
+			errs = append(errs, cErr.Errors...)
+		} else if nv, ok := err.(notValidated); ok {
+			skipped = nv
+		} else if err != nil {
+			errs = append(errs, err)
 		}
 	}
 
+	// A body which can't be validated only matters without violations
 	if len(errs) == 0 {
-		return nil
+		return skipped
 	}
 	return errors.CompositeValidationError(errs...)
 }
 
-func (proxy *Proxy) ValidateMIME(resp Response, op *spec.Operation) error {
+// appendError adds err to the errors of errs, which may be nil or a
+// CompositeError.
+func appendError(errs, err error) error {
+	if err == nil {
+		return errs
+	}
+	if errs == nil {
+		return err
+	}
+	if cErr, ok := errs.(*errors.CompositeError); ok {
+		return errors.CompositeValidationError(append(cErr.Errors, err)...)
+	}
+	return errors.CompositeValidationError(errs, err)
+}
+
+func (st *state) validateMIME(resp Response, op *spec.Operation) error {
 	// Use Operation Spec or fallback to root
 	produces := op.Produces
 	if len(produces) == 0 {
-		produces = proxy.spec.Produces
+		produces = st.spec.Produces
 	}
 
 	ct := resp.Header().Get("Content-Type")
@@ -184,21 +625,24 @@ func (proxy *Proxy) ValidateMIME(resp Response, op *spec.Operation) error {
 		return nil
 	}
 
-	for _, mime := range produces {
-		if ct == mime {
-			return nil
-		}
+	if mediaTypeMatches(produces, ct) {
+		return nil
 	}
 
 	return fmt.Errorf("Content-Type Error: Should produce %q, but got: '%s'", produces, ct)
 }
 
-func (proxy *Proxy) ValidateHeaders(resp Response, op *spec.Operation) error {
+func (st *state) validateHeaders(resp Response, op *spec.Operation) error {
 	var errs []error
 
-	r := op.Responses.StatusCodeResponses[resp.Status()]
+	r, ok := operationResponse(op, resp.Status())
+	if !ok {
+		return nil
+	}
+
 	for key, spec := range r.Headers {
-		if err := validateHeaderValue(key, resp.Header().Get(key), &spec); err != nil {
+		values := resp.Header()[http.CanonicalHeaderKey(key)]
+		if err := validateHeaderValue(key, values, &spec); err != nil {
 			errs = append(errs, err)
 		}
 	}
@@ -210,18 +654,35 @@ func (proxy *Proxy) ValidateHeaders(resp Response, op *spec.Operation) error {
 	return errors.CompositeValidationError(errs...)
 }
 
-func (proxy *Proxy) ValidateBody(resp Response, op *spec.Operation) error {
-	r := op.Responses.StatusCodeResponses[resp.Status()]
-	if r.Schema == nil {
+func (st *state) validateBody(resp Response, op *spec.Operation) error {
+	r, ok := operationResponse(op, resp.Status())
+	if !ok || r.Schema == nil || streamOnly(op) || isBinary(r.Schema) {
+		return nil
+	}
+
+	if t, ok := resp.(interface{ Truncated() bool }); ok && t.Truncated() {
 		return nil
 	}
 
+	if isEventStream(resp.Header()) {
+		return st.validateEvents(op, resp.Status(), resp.Body())
+	}
+
+	body, err := decodeBody(resp, st.maxBodySize)
+	if err != nil {
+		return err
+	}
+
 	var data interface{}
-	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+	if isXML(resp.Header().Get("Content-Type")) {
+		if data, err = st.decodeXML(r.Schema, body); err != nil {
+			return err
+		}
+	} else if err := json.Unmarshal(body, &data); err != nil {
 		return err
 	}
 
-	v := validate.NewSchemaValidator(r.Schema, proxy.doc, "", strfmt.Default)
+	v := validate.NewSchemaValidator(r.Schema, st.doc, "", strfmt.Default)
 	if result := v.Validate(data); result.HasErrors() {
 		return result.AsError()
 	}
@@ -229,33 +690,141 @@ func (proxy *Proxy) ValidateBody(resp Response, op *spec.Operation) error {
 	return nil
 }
 
-func validateHeaderValue(key, value string, spec *spec.Header) error {
-	if value == "" {
-		return fmt.Errorf("%s in headers is missing", key)
+func streamOnly(op *spec.Operation) bool {
+	if stream, _ := op.Extensions.GetBool(StreamExtension); stream {
+		return true
 	}
 
-	// TODO: Implement the rest of the format validators
-	switch spec.Format {
-	case "int32":
-		_, err := swag.ConvertInt32(value)
+	if op.Responses == nil {
+		return false
+	}
+	for _, r := range op.Responses.StatusCodeResponses {
+		if r.Schema != nil && isBinary(r.Schema) {
+			return true
+		}
+	}
+	return false
+}
+
+func isBinary(schema *spec.Schema) bool {
+	return schema.Type.Contains("file") || (schema.Type.Contains("string") && schema.Format == "binary")
+}
+
+// Response returns the response defined for status, looking for the exact
+// status code first, then its status class (e.g. 4XX) and finally the
+// default response.
+func (proxy *Proxy) Response(op *spec.Operation, status int) (*spec.Response, bool) {
+	return operationResponse(op, status)
+}
+
+func operationResponse(op *spec.Operation, status int) (*spec.Response, bool) {
+	if op.Responses == nil {
+		return nil, false
+	}
+
+	if r, ok := op.Responses.StatusCodeResponses[status]; ok {
+		return &r, true
+	}
+
+	class := fmt.Sprintf("%dXX", status/100)
+	if r, ok := statusRanges(op.Responses)[class]; ok {
+		return &r, true
+	}
+
+	if op.Responses.Default != nil {
+		return op.Responses.Default, true
+	}
+	return nil, false
+}
+
+func statusRanges(responses *spec.Responses) map[string]spec.Response {
+	ext, ok := responses.Extensions[StatusRangesExtension]
+	if !ok {
+		return nil
+	}
+
+	if ranges, ok := ext.(map[string]spec.Response); ok {
+		return ranges
+	}
+
+	// Ranges declared on a Swagger 2.0 document are still raw JSON
+	data, err := json.Marshal(ext)
+	if err != nil {
+		return nil
+	}
+
+	var ranges map[string]spec.Response
+	if err := json.Unmarshal(data, &ranges); err != nil {
+		return nil
+	}
+
+	normalized := make(map[string]spec.Response)
+	for class, r := range ranges {
+		normalized[strings.ToUpper(class)] = r
+	}
+	return normalized
+}
+
+// validateHeaderValue validates the values of a response header against its
+// definition. Headers are optional unless marked with the
+// RequiredHeaderExtension.
+func validateHeaderValue(key string, values []string, header *spec.Header) error {
+	if len(values) == 0 {
+		if required, _ := header.Extensions.GetBool(RequiredHeaderExtension); required {
+			return errors.Required(key, "header")
+		}
+		return nil
+	}
+
+	value := values[0]
+	if header.Type == "array" {
+		// Repeated headers are equivalent to a single comma separated one
+		value = strings.Join(values, ",")
+	}
+
+	data, err := convertValue(key, "header", &header.SimpleSchema, []string{value})
+	if err != nil {
 		return err
-	case "date-time":
-		_, err := strfmt.ParseDateTime(value)
+	}
+
+	// The format validator doesn't apply to headers
+	if err := validateHeaderFormat(key, header.Format, header.Items, data); err != nil {
 		return err
 	}
+
+	if result := validate.NewHeaderValidator(key, header, strfmt.Default).Validate(data); result != nil && result.HasErrors() {
+		return result.AsError()
+	}
 	return nil
 }
 
-func (proxy *Proxy) PendingOperations() []*spec.Operation {
-	var ops []*spec.Operation
-	for op, _ := range proxy.pendingOperations {
-		ops = append(ops, op)
+// validateHeaderFormat checks the formats of a header and of its items,
+// which the header validator leaves out.
+func validateHeaderFormat(key, format string, items *spec.Items, data interface{}) error {
+	switch v := data.(type) {
+	case []interface{}:
+		if items == nil {
+			return nil
+		}
+		for i, item := range v {
+			if err := validateHeaderFormat(fmt.Sprintf("%s.%d", key, i), items.Format, items.Items, item); err != nil {
+				return err
+			}
+		}
+	case int64:
+		if format == "int32" && (v < math.MinInt32 || v > math.MaxInt32) {
+			return errors.InvalidType(key, "header", format, v)
+		}
+	case string:
+		if format != "" && strfmt.Default.ContainsName(format) && !strfmt.Default.Validates(format, v) {
+			return errors.InvalidType(key, "header", format, v)
+		}
 	}
-	return ops
+	return nil
 }
 
-func (proxy *Proxy) operationExecuted(op *spec.Operation) {
-	delete(proxy.pendingOperations, op)
+func (proxy *Proxy) PendingOperations() []*spec.Operation {
+	return proxy.current().pending.list()
 }
 
 type WalkOpsFunc func(path, meth string, op *spec.Operation)
@@ -268,6 +837,15 @@ func WalkOps(spec *spec.Swagger, fn WalkOpsFunc) {
 	}
 }
 
+// OperationName returns the name operations are reported with, their
+// operationId or else their summary.
+func OperationName(op *spec.Operation) string {
+	if op.ID != "" {
+		return op.ID
+	}
+	return op.Summary
+}
+
 func getOperations(props *spec.PathItem) map[string]*spec.Operation {
 	ops := map[string]*spec.Operation{
 		"DELETE":  props.Delete,
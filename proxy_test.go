@@ -1,13 +1,20 @@
 package proxy
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/go-openapi/loads"
 	"github.com/go-openapi/spec"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -23,9 +30,10 @@ func (t *testResponse) Header() http.Header { return t.header }
 func (t *testResponse) Body() []byte        { return t.body }
 
 type testReporter struct {
-	success  []*http.Request
-	errors   []error
-	warnings []string
+	success       []*http.Request
+	errors        []error
+	requestErrors []error
+	warnings      []string
 }
 
 func (t *testReporter) Success(req *http.Request) {
@@ -36,6 +44,10 @@ func (t *testReporter) Error(req *http.Request, err error) {
 	t.errors = append(t.errors, err)
 }
 
+func (t *testReporter) RequestError(req *http.Request, err error) {
+	t.requestErrors = append(t.requestErrors, err)
+}
+
 func (t *testReporter) Warning(req *http.Request, msg string) {
 	t.warnings = append(t.warnings, msg)
 }
@@ -70,6 +82,36 @@ func TestValidation(t *testing.T) {
 	assert.Error(t, app.Validate(resp, op))
 }
 
+func TestResponseFallback(t *testing.T) {
+	swagger := openFixture(t, "petstore.json")
+	app, err := New(swagger, nil)
+	require.NoError(t, err)
+
+	op := swagger.Paths.Paths["/store/inventory"].Get
+	resp := &testResponse{
+		status: 500,
+		header: http.Header{},
+		body:   []byte(`{"message": "boom"}`),
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	require.Error(t, app.Validate(resp, op), "500 is not defined")
+
+	op.Responses.Default = spec.NewResponse().WithSchema(
+		new(spec.Schema).Typed("object", "").WithRequired("message"),
+	)
+	assert.NoError(t, app.Validate(resp, op), "500 falls back to default")
+
+	resp.body = []byte(`{}`)
+	assert.Error(t, app.Validate(resp, op), "default schema is validated")
+
+	t.Run("StatusRanges", func(t *testing.T) {
+		op.Responses.AddExtension(StatusRangesExtension, map[string]interface{}{
+			"5xx": map[string]interface{}{"description": "Server Error"},
+		})
+		assert.NoError(t, app.Validate(resp, op), "500 falls back to 5XX")
+	})
+}
+
 func TestHeaderValidation(t *testing.T) {
 	swagger := openFixture(t, "petstore.json")
 	proxy, err := New(swagger, nil)
@@ -96,6 +138,75 @@ func TestHeaderValidation(t *testing.T) {
 	})
 }
 
+func TestHeaderConstraints(t *testing.T) {
+	header := func(tpe, format string) *spec.Header {
+		h := spec.ResponseHeader()
+		h.Type = tpe
+		h.Format = format
+		return h
+	}
+
+	enum := header("string", "")
+	enum.Enum = []interface{}{"a", "b"}
+
+	pattern := header("string", "")
+	pattern.Pattern = "^v[0-9]+$"
+
+	length := header("string", "")
+	length.WithMaxLength(3)
+
+	limited := header("integer", "int32")
+	limited.WithMinimum(1, false).WithMaximum(10, false)
+
+	array := header("array", "")
+	array.CollectionFormat = "pipes"
+	array.Items = spec.NewItems().Typed("integer", "")
+	array.WithMaxItems(2)
+
+	uuids := header("array", "")
+	uuids.Items = spec.NewItems().Typed("string", "uuid")
+
+	required := header("string", "")
+	required.AddExtension(RequiredHeaderExtension, true)
+
+	for _, test := range []struct {
+		header *spec.Header
+		values []string
+		valid  bool
+	}{
+		{header("string", ""), nil, true},
+		{required, nil, false},
+		{required, []string{"x"}, true},
+		{header("integer", ""), []string{"1"}, true},
+		{header("integer", ""), []string{"1.5"}, false},
+		{header("integer", "int32"), []string{"4294967296"}, false},
+		{header("number", ""), []string{"1.5"}, true},
+		{header("boolean", ""), []string{"maybe"}, false},
+		{header("string", "uuid"), []string{"a8098c1a-f86e-11da-bd1a-00112444be1e"}, true},
+		{header("string", "uuid"), []string{"nope"}, false},
+		{header("string", "email"), []string{"nope"}, false},
+		{header("string", "date"), []string{"2017-05-25"}, true},
+		{enum, []string{"b"}, true},
+		{enum, []string{"c"}, false},
+		{pattern, []string{"v2"}, true},
+		{pattern, []string{"2"}, false},
+		{length, []string{"abcd"}, false},
+		{limited, []string{"10"}, true},
+		{limited, []string{"11"}, false},
+		{array, []string{"1|2"}, true},
+		{array, []string{"1|2|3"}, false},
+		{array, []string{"1|x"}, false},
+		{uuids, []string{"a8098c1a-f86e-11da-bd1a-00112444be1e", "nope"}, false},
+	} {
+		err := validateHeaderValue("X-Test", test.values, test.header)
+		if test.valid {
+			assert.NoError(t, err, "%s %s %v", test.header.Type, test.header.Format, test.values)
+		} else {
+			assert.Error(t, err, "%s %s %v", test.header.Type, test.header.Format, test.values)
+		}
+	}
+}
+
 func TestProducesDefinition(t *testing.T) {
 	swagger := openFixture(t, "petstore.json")
 	app, err := New(swagger, nil)
@@ -152,6 +263,189 @@ func TestHTTPHandler(t *testing.T) {
 	assert.Equal(t, 1, len(reporter.success))
 	assert.Equal(t, 1, len(reporter.errors))
 	assert.Equal(t, 1, len(reporter.warnings))
+	// findByStatus is missing its required `status` query parameter
+	assert.Equal(t, 1, len(reporter.requestErrors))
+}
+
+func TestRequestValidation(t *testing.T) {
+	swagger := openFixture(t, "petstore.json")
+	app, err := New(swagger, nil)
+	require.NoError(t, err)
+
+	validate := func(method, url, body string, vars map[string]string, op *spec.Operation) error {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return app.ValidateRequest(req, vars, op)
+	}
+
+	t.Run("Query", func(t *testing.T) {
+		op := swagger.Paths.Paths["/pet/findByStatus"].Get
+		assert.NoError(t, validate("GET", "/v2/pet/findByStatus?status=sold", "", nil, op))
+		assert.NoError(t, validate("GET", "/v2/pet/findByStatus?status=sold&status=pending", "", nil, op))
+		assert.Error(t, validate("GET", "/v2/pet/findByStatus", "", nil, op),
+			"status is required")
+		assert.Error(t, validate("GET", "/v2/pet/findByStatus?status=lost", "", nil, op),
+			"status is not part of the enum")
+	})
+
+	t.Run("Path", func(t *testing.T) {
+		op := swagger.Paths.Paths["/pet/{petId}"].Get
+		assert.NoError(t, validate("GET", "/v2/pet/1", "", map[string]string{"petId": "1"}, op))
+		assert.Error(t, validate("GET", "/v2/pet/x", "", map[string]string{"petId": "x"}, op),
+			"petId is not an integer")
+	})
+
+	t.Run("Body", func(t *testing.T) {
+		op := swagger.Paths.Paths["/pet"].Post
+		assert.NoError(t, validate("POST", "/v2/pet", `{"name": "doggie", "photoUrls": []}`, nil, op))
+		assert.Error(t, validate("POST", "/v2/pet", "", nil, op),
+			"body is required")
+		assert.Error(t, validate("POST", "/v2/pet", `{"name": "doggie"}`, nil, op),
+			"photoUrls is required")
+	})
+
+	t.Run("ContentType", func(t *testing.T) {
+		op := swagger.Paths.Paths["/pet"].Post
+		validate := func(contentType, body string) error {
+			req := httptest.NewRequest("POST", "/v2/pet", strings.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			return app.ValidateRequest(req, nil, op)
+		}
+
+		assert.NoError(t, validate("application/xml", `<Pet><name>doggie</name><photoUrl><photoUrls>a.png</photoUrls></photoUrl></Pet>`))
+		assert.Error(t, validate("application/xml", `<Pet><id>one</id><name>doggie</name><photoUrl/></Pet>`),
+			"id is not an integer")
+		assert.Equal(t, notValidated(`Request Content-Type "text/plain" can't be validated, not validated`),
+			validate("text/plain", "doggie"))
+	})
+
+	t.Run("BodyIsPreserved", func(t *testing.T) {
+		op := swagger.Paths.Paths["/pet"].Post
+		body := `{"name": "doggie", "photoUrls": []}`
+		req := httptest.NewRequest("POST", "/v2/pet", strings.NewReader(body))
+		require.NoError(t, app.ValidateRequest(req, nil, op))
+
+		data, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, body, string(data))
+	})
+
+	t.Run("BodyNotRead", func(t *testing.T) {
+		op := swagger.Paths.Paths["/pet/{petId}"].Get
+		req := httptest.NewRequest("GET", "/v2/pet/1", strings.NewReader("unread"))
+		body := req.Body
+		require.NoError(t, app.ValidateRequest(req, map[string]string{"petId": "1"}, op))
+		assert.Equal(t, body, req.Body)
+	})
+}
+
+func TestRequestBodyTooLarge(t *testing.T) {
+	swagger := openFixture(t, "petstore.json")
+	reporter := &testReporter{}
+	app, err := New(swagger, reporter, WithMaxBodySize(16))
+	require.NoError(t, err)
+
+	// Not validated, but still forwarded as a whole
+	body := `{"name": "doggie", "photoUrls": []}`
+	req := httptest.NewRequest("POST", "/v2/pet", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	assert.Equal(t, errRequestBodyTooLarge, app.ValidateRequest(req, nil, swagger.Paths.Paths["/pet"].Post))
+
+	data, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(data))
+
+	// Reported along with the response, rather than as a violation
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		io.Copy(ioutil.Discard, req.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+	req = httptest.NewRequest("POST", "/v2/pet", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	app.Handler(http.HandlerFunc(fn)).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Empty(t, reporter.requestErrors)
+	assert.Empty(t, reporter.errors)
+	assert.Equal(t, []string{"Request body too large, not validated"}, reporter.warnings)
+}
+
+func TestEnforce(t *testing.T) {
+	swagger := openFixture(t, "petstore.json")
+	app, err := New(swagger, &testReporter{}, WithEnforce(true))
+	require.NoError(t, err)
+
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Upstream", "true")
+		w.Write([]byte("{}"))
+	}
+
+	srv := httptest.NewServer(app.Handler(http.HandlerFunc(fn)))
+	defer srv.Close()
+
+	t.Run("ConformingResponse", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/v2/store/inventory")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "true", resp.Header.Get("X-Upstream"))
+		assert.Equal(t, "{}", string(body))
+	})
+
+	t.Run("NonConformingResponse", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/v2/pet/findByStatus?status=sold")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+		assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+		assert.Empty(t, resp.Header.Get("X-Upstream"))
+
+		var problem Problem
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+		assert.Equal(t, http.StatusBadGateway, problem.Status)
+		assert.NotEmpty(t, problem.Violations)
+	})
+}
+
+func TestVerboseOutput(t *testing.T) {
+	var out bytes.Buffer
+	_, err := New(openFixture(t, "petstore.json"), nil, WithVerbose(true), WithOutput(&out))
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "Register GET /v2/pet/findByStatus\n")
+}
+
+func TestRouteOrder(t *testing.T) {
+	swagger := openFixture(t, "petstore.json")
+	app, err := New(swagger, nil)
+	require.NoError(t, err)
+
+	var ops []string
+	for _, op := range app.current().operations {
+		ops = append(ops, op.method+" "+op.path)
+	}
+	index := func(op string) int {
+		for i, o := range ops {
+			if o == op {
+				return i
+			}
+		}
+		t.Fatalf("%s not registered", op)
+		return -1
+	}
+	// Literal paths are routed before templated ones
+	assert.True(t, index("GET /pet/findByStatus") < index("GET /pet/{petId}"))
+	assert.True(t, index("DELETE /pet/{petId}") < index("GET /pet/{petId}"))
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, app.SetSpec(swagger))
+		req := httptest.NewRequest("GET", "/v2/pet/findByStatus", nil)
+		var match mux.RouteMatch
+		require.True(t, app.current().router.Match(req, &match))
+		assert.Equal(t, "findPetsByStatus", app.current().routes[match.Route].ID)
+	}
 }
 
 func TestPendingOperations(t *testing.T) {
@@ -198,4 +492,78 @@ func TestPendingOperations(t *testing.T) {
 		http.Get(srv.URL + "/v2/store/inventory")
 		assert.Equal(t, pending-1, len(app.PendingOperations()))
 	})
+
+	t.Run("Coverage", func(t *testing.T) {
+		covered, total := app.Coverage()
+		assert.Equal(t, opsCounter, total)
+		assert.Equal(t, 3, covered)
+	})
+}
+
+func TestConcurrentSpecReload(t *testing.T) {
+	swagger := openFixture(t, "petstore.json")
+	app, err := New(swagger, NewJSONReporter(ioutil.Discard))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(app.Handler(
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}),
+	))
+	defer srv.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				resp, err := http.Get(srv.URL + "/v2/store/inventory")
+				if err == nil {
+					resp.Body.Close()
+				}
+				app.PendingOperations()
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, app.SetSpec(openFixture(t, "petstore.json")))
+	}
+	wg.Wait()
+}
+
+func TestConcurrentSpecReloadCoverage(t *testing.T) {
+	app, err := New(openFixture(t, "petstore.json"), NewJSONReporter(ioutil.Discard), WithMock(true))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(app.Router())
+	defer srv.Close()
+
+	// Reloads and resets racing each other leave the coverage of a single spec
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				app.SetSpec(openFixture(t, "petstore.json"))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				app.Reset()
+				if resp, err := http.Get(srv.URL + "/v2/store/inventory"); err == nil {
+					resp.Body.Close()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	app.Reset()
+	app.Router().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v2/store/inventory", nil))
+
+	covered, total := app.Coverage()
+	assert.Equal(t, 1, covered)
+	assert.Equal(t, total-1, len(app.PendingOperations()))
 }
@@ -0,0 +1,176 @@
+// Package proxytest validates the exchanges of Go tests against a spec.
+//
+//	func TestPets(t *testing.T) {
+//		doc, _ := proxy.LoadSpec("swagger.yml")
+//		v := proxytest.New(t, doc)
+//		srv := v.NewServer(api.Handler())
+//		v.RequireOperationsCovered(t, "getPetById")
+//
+//		http.Get(srv.URL + "/v2/pet/1")
+//	}
+//
+// Once the test completes, it fails with the exchanges which didn't conform
+// to the spec.
+package proxytest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	proxy "github.com/gchaincl/swagger-proxy"
+	"github.com/go-openapi/spec"
+)
+
+// Validator validates exchanges against a spec on behalf of a test.
+type Validator struct {
+	*proxy.Proxy
+
+	t        testing.TB
+	doc      *spec.Swagger
+	reporter *reporter
+}
+
+// New returns a Validator failing t, on cleanup, if any exchange didn't
+// conform to doc.
+func New(t testing.TB, doc *spec.Swagger, opts ...proxy.ProxyOpt) *Validator {
+	t.Helper()
+
+	r := &reporter{}
+	px, err := proxy.New(doc, r, opts...)
+	if err != nil {
+		t.Fatalf("proxytest: %s", err)
+	}
+
+	v := &Validator{Proxy: px, t: t, doc: doc, reporter: r}
+	t.Cleanup(v.check)
+	return v
+}
+
+// NewServer starts a server serving h, validated against the spec. It is
+// closed on cleanup.
+func (v *Validator) NewServer(h http.Handler) *httptest.Server {
+	srv := httptest.NewServer(v.Handler(h))
+	v.t.Cleanup(srv.Close)
+	return srv
+}
+
+// WrapServer starts a server forwarding the requests to srv and validating
+// its responses against the spec. It is closed on cleanup.
+func (v *Validator) WrapServer(srv *httptest.Server) *httptest.Server {
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		v.t.Fatalf("proxytest: %s", err)
+	}
+	return v.NewServer(httputil.NewSingleHostReverseProxy(target))
+}
+
+// RequireOperationsCovered fails t, on cleanup, unless the operations with
+// the given ids have been exercised. Without ids, every operation of the
+// spec has to be.
+func (v *Validator) RequireOperationsCovered(t testing.TB, ids ...string) {
+	t.Helper()
+	t.Cleanup(func() {
+		pending := make(map[string]bool)
+		var all []string
+		for _, op := range v.PendingOperations() {
+			pending[op.ID] = true
+			all = append(all, proxy.OperationName(op))
+		}
+
+		if len(ids) == 0 {
+			if len(all) > 0 {
+				t.Errorf("%d operations not covered:\n\t%s", len(all), strings.Join(all, "\n\t"))
+			}
+			return
+		}
+
+		var missing []string
+		for _, id := range ids {
+			if pending[id] || !v.hasOperation(id) {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			t.Errorf("operations not covered: %s", strings.Join(missing, ", "))
+		}
+	})
+}
+
+func (v *Validator) hasOperation(id string) bool {
+	found := false
+	proxy.WalkOps(v.doc, func(_, _ string, op *spec.Operation) {
+		found = found || op.ID == id
+	})
+	return found
+}
+
+func (v *Validator) check() {
+	v.t.Helper()
+
+	failures, warnings := v.reporter.results()
+	for _, w := range warnings {
+		v.t.Log(w)
+	}
+	if len(failures) > 0 {
+		v.t.Errorf("%d exchanges didn't conform to the spec:\n\n%s", len(failures), strings.Join(failures, "\n\n"))
+	}
+}
+
+// reporter collects readable messages for the exchanges, which can be
+// reported from the servers goroutines.
+type reporter struct {
+	mu       sync.Mutex
+	failures []string
+	warnings []string
+}
+
+func (r *reporter) Success(req *http.Request) {}
+
+func (r *reporter) Error(req *http.Request, err error) {
+	r.fail(req, "response", err)
+}
+
+func (r *reporter) RequestError(req *http.Request, err error) {
+	r.fail(req, "request", err)
+}
+
+func (r *reporter) Warning(req *http.Request, msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.warnings = append(r.warnings, fmt.Sprintf("%s %s: %s", req.Method, req.URL.Path, msg))
+}
+
+func (r *reporter) Report() {}
+
+func (r *reporter) fail(req *http.Request, what string, err error) {
+	msg := fmt.Sprintf("%s %s", req.Method, req.URL.Path)
+	if ex := proxy.ExchangeOf(req); ex != nil {
+		if ex.Operation != nil && ex.Operation.ID != "" {
+			msg += fmt.Sprintf(" (%s)", ex.Operation.ID)
+		}
+		if what == "response" && ex.Status != 0 {
+			msg += fmt.Sprintf(" -> %d", ex.Status)
+		}
+	}
+
+	msg += fmt.Sprintf(": invalid %s", what)
+	for _, v := range proxy.Violations(err) {
+		msg += "\n\t" + v.Message
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = append(r.failures, msg)
+}
+
+func (r *reporter) results() (failures, warnings []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.failures, r.warnings
+}
@@ -0,0 +1,90 @@
+package proxytest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	proxy "github.com/gchaincl/swagger-proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeT records the failures instead of failing the test, and runs the
+// cleanups when done is called.
+type fakeT struct {
+	testing.TB
+	errors   []string
+	logs     []string
+	cleanups []func()
+}
+
+func (t *fakeT) Helper()                 {}
+func (t *fakeT) Cleanup(fn func())       { t.cleanups = append(t.cleanups, fn) }
+func (t *fakeT) Log(args ...interface{}) { t.logs = append(t.logs, fmt.Sprint(args...)) }
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) done() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func petHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	})
+}
+
+func TestValidator(t *testing.T) {
+	doc, err := proxy.LoadSpec("../fixtures/petstore.json")
+	require.NoError(t, err)
+
+	t.Run("Conforming", func(t *testing.T) {
+		ft := &fakeT{TB: t}
+		v := New(ft, doc)
+		srv := v.NewServer(petHandler(`{"name": "doggie", "photoUrls": []}`))
+		v.RequireOperationsCovered(ft, "getPetById")
+
+		_, err := http.Get(srv.URL + "/v2/pet/1")
+		require.NoError(t, err)
+		_, err = http.Get(srv.URL + "/v2/unknown")
+		require.NoError(t, err)
+
+		ft.done()
+		assert.Empty(t, ft.errors)
+		assert.Equal(t, []string{"GET /v2/unknown: Route not defined on the Spec"}, ft.logs)
+	})
+
+	t.Run("Violations", func(t *testing.T) {
+		ft := &fakeT{TB: t}
+		v := New(ft, doc)
+		target := httptest.NewServer(petHandler(`{"photoUrls": []}`))
+		defer target.Close()
+		srv := v.WrapServer(target)
+		v.RequireOperationsCovered(ft, "getPetById", "addPet", "unknown")
+
+		_, err := http.Get(srv.URL + "/v2/pet/1")
+		require.NoError(t, err)
+
+		ft.done()
+		require.Len(t, ft.errors, 2)
+		assert.Equal(t, "operations not covered: addPet, unknown", ft.errors[0])
+		assert.Equal(t, "1 exchanges didn't conform to the spec:\n\n"+
+			"GET /v2/pet/1 (getPetById) -> 200: invalid response\n\t.name in body is required", ft.errors[1])
+	})
+
+	t.Run("AllOperations", func(t *testing.T) {
+		ft := &fakeT{TB: t}
+		v := New(ft, doc)
+		v.RequireOperationsCovered(ft)
+
+		ft.done()
+		require.Len(t, ft.errors, 1)
+		assert.Contains(t, ft.errors[0], "20 operations not covered:\n\t")
+	})
+}
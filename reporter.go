@@ -1,11 +1,14 @@
 package proxy
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/go-openapi/errors"
+	"github.com/go-openapi/spec"
 )
 
 type Reporter interface {
@@ -15,6 +18,53 @@ type Reporter interface {
 	Report()
 }
 
+// RequestReporter is implemented by the reporters which report request
+// violations as soon as the request is validated. Every reporter finds them
+// on the Exchange once the response outcome is reported.
+type RequestReporter interface {
+	RequestError(req *http.Request, err error)
+}
+
+// Exchange describes the request/response pair a reported request belongs
+// to. Path is the spec path template the request matched, without the
+// BasePath. RequestErr holds the violations of the request left by the
+// rules, if any.
+type Exchange struct {
+	Path       string
+	Operation  *spec.Operation
+	Status     int
+	Duration   time.Duration // Time taken by the target to respond
+	RequestErr error
+
+	start       time.Time
+	requestBody []byte // Only kept when recording
+	// Reported along with the outcome of the response, such as the request
+	// violations downgraded by the rules
+	requestWarnings []string
+	response        Response
+}
+
+type exchangeKey struct{}
+
+// ExchangeOf returns the Exchange attached by the Proxy to a reported
+// request, or nil if there is none.
+func ExchangeOf(req *http.Request) *Exchange {
+	ex, _ := req.Context().Value(exchangeKey{}).(*Exchange)
+	return ex
+}
+
+func (proxy *Proxy) newExchange(req *http.Request, op *spec.Operation) (*http.Request, *Exchange) {
+	ex := &Exchange{Operation: op, start: time.Now()}
+	if proxy.har != nil {
+		ex.requestBody, _ = readBody(req, proxy.maxBodySize)
+	}
+	return withExchange(req, ex), ex
+}
+
+func withExchange(req *http.Request, ex *Exchange) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), exchangeKey{}, ex))
+}
+
 type LogReporter struct {
 }
 
@@ -28,14 +78,14 @@ func (r *LogReporter) Error(req *http.Request, err error) {
 	fmt.Fprintf(color.Output, "%s %s %s\n",
 		color.RedString("✗"), req.Method, req.URL,
 	)
-	if cErr, ok := err.(*errors.CompositeError); ok {
-		for i, err := range cErr.Errors {
-			fmt.Printf("  %d) %s\n", i+1, err)
-		}
+	printErrors(err)
+}
 
-	} else {
-		fmt.Printf("  => %s\n", err)
-	}
+func (r *LogReporter) RequestError(req *http.Request, err error) {
+	fmt.Fprintf(color.Output, "%s %s %s (request)\n",
+		color.RedString("✗"), req.Method, req.URL,
+	)
+	printErrors(err)
 }
 
 func (r *LogReporter) Warning(req *http.Request, msg string) {
@@ -46,3 +96,14 @@ func (r *LogReporter) Warning(req *http.Request, msg string) {
 }
 
 func (r *LogReporter) Report() {}
+
+func printErrors(err error) {
+	if cErr, ok := err.(*errors.CompositeError); ok {
+		for i, err := range cErr.Errors {
+			fmt.Printf("  %d) %s\n", i+1, err)
+		}
+
+	} else {
+		fmt.Printf("  => %s\n", err)
+	}
+}
@@ -0,0 +1,334 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/errors"
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/swag"
+	"github.com/go-openapi/validate"
+)
+
+// ValidateRequest checks the incoming request against the operation
+// parameters. vars holds the path parameters extracted by the router.
+func (proxy *Proxy) ValidateRequest(req *http.Request, vars map[string]string, op *spec.Operation) error {
+	return proxy.current().validateRequest(req, vars, op)
+}
+
+func (st *state) validateRequest(req *http.Request, vars map[string]string, op *spec.Operation) error {
+	params, ok := st.params[op]
+	if !ok {
+		params = op.Parameters
+	}
+
+	var inBody, inForm bool
+	for _, param := range params {
+		inBody = inBody || param.In == "body"
+		inForm = inForm || param.In == "formData"
+	}
+
+	// The body is only read when there are parameters in it, and isn't
+	// validated when too large
+	var body []byte
+	var form url.Values
+	var files map[string][]*multipart.FileHeader
+	skipBody := false
+	if inBody || inForm {
+		var err error
+		body, err = readBody(req, st.maxBodySize)
+		skipBody = err == errRequestBodyTooLarge
+		if err != nil && !skipBody {
+			return err
+		}
+	}
+	if inForm && !skipBody {
+		var err error
+		if form, files, err = parseForm(req.Header.Get("Content-Type"), body); err != nil {
+			return err
+		}
+	}
+
+	// Reported when nothing else is wrong
+	var skipped notValidated
+	if skipBody {
+		skipped = errRequestBodyTooLarge
+	}
+
+	var errs []error
+	for i := range params {
+		param := &params[i]
+		if skipBody && (param.In == "body" || param.In == "formData") {
+			continue
+		}
+
+		var err error
+		switch param.In {
+		case "body":
+			err = st.validateBodyParam(param, req.Header.Get("Content-Type"), body)
+		case "path":
+			values := []string{}
+			if v, ok := vars[param.Name]; ok {
+				values = append(values, v)
+			}
+			err = validateParam(param, values)
+		case "query":
+			err = validateParam(param, req.URL.Query()[param.Name])
+		case "header":
+			err = validateParam(param, req.Header[http.CanonicalHeaderKey(param.Name)])
+		case "cookie":
+			values := []string{}
+			if c, err := req.Cookie(param.Name); err == nil {
+				values = append(values, c.Value)
+			}
+			err = validateParam(param, values)
+		case "formData":
+			if param.Type == "file" {
+				if param.Required && len(files[param.Name]) == 0 {
+					err = errors.Required(param.Name, param.In)
+				}
+				break
+			}
+			err = validateParam(param, form[param.Name])
+		}
+
+		if nv, ok := err.(notValidated); ok {
+			skipped = nv
+			continue
+		}
+		if err != nil {
+			if cErr, ok := err.(*errors.CompositeError); ok {
+				errs = append(errs, cErr.Errors...)
+			} else {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		if skipped != "" {
+			return skipped
+		}
+		return nil
+	}
+	return errors.CompositeValidationError(errs...)
+}
+
+// validateBodyParam validates a JSON or XML body, bodies of any other media
+// type aren't validated. JSON is assumed without a Content-Type.
+func (st *state) validateBodyParam(param *spec.Parameter, contentType string, body []byte) error {
+	if len(body) == 0 {
+		if param.Required {
+			return errors.Required(param.Name, param.In)
+		}
+		return nil
+	}
+
+	if param.Schema == nil {
+		return nil
+	}
+
+	var data interface{}
+	var err error
+	switch {
+	case contentType == "" || isJSON(contentType):
+		err = json.Unmarshal(body, &data)
+	case isXML(contentType):
+		data, err = st.decodeXML(param.Schema, body)
+	default:
+		return notValidated(fmt.Sprintf("Request Content-Type %q can't be validated, not validated", contentType))
+	}
+	if err != nil {
+		return err
+	}
+
+	v := validate.NewSchemaValidator(param.Schema, st.doc, "", strfmt.Default)
+	if result := v.Validate(data); result.HasErrors() {
+		return result.AsError()
+	}
+	return nil
+}
+
+func validateParam(param *spec.Parameter, values []string) error {
+	if len(values) == 0 {
+		if param.Required {
+			return errors.Required(param.Name, param.In)
+		}
+		return nil
+	}
+
+	data, err := convertValue(param.Name, param.In, &param.SimpleSchema, values)
+	if err != nil {
+		return err
+	}
+
+	if result := validate.NewParamValidator(param, strfmt.Default).Validate(data); result != nil && result.HasErrors() {
+		return result.AsError()
+	}
+	return nil
+}
+
+// convertValue turns the raw string values into the Go type described by
+// the schema, so they can be checked by the validate package.
+func convertValue(name, in string, schema *spec.SimpleSchema, values []string) (interface{}, error) {
+	if schema.Type == "array" {
+		if schema.CollectionFormat != "multi" {
+			values = splitCollection(values[0], schema.CollectionFormat)
+		}
+
+		items := make([]interface{}, len(values))
+		for i, value := range values {
+			var item interface{} = value
+			if schema.Items != nil {
+				var err error
+				if item, err = convertValue(name, in, &schema.Items.SimpleSchema, []string{value}); err != nil {
+					return nil, err
+				}
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+
+	value := values[0]
+	switch schema.Type {
+	case "integer":
+		i, err := swag.ConvertInt64(value)
+		if err != nil {
+			return nil, errors.InvalidType(name, in, "integer", value)
+		}
+		return i, nil
+	case "number":
+		f, err := swag.ConvertFloat64(value)
+		if err != nil {
+			return nil, errors.InvalidType(name, in, "number", value)
+		}
+		return f, nil
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.InvalidType(name, in, "boolean", value)
+		}
+		return b, nil
+	}
+	return value, nil
+}
+
+func splitCollection(value, format string) []string {
+	if value == "" {
+		return []string{}
+	}
+
+	return strings.Split(value, collectionSeparator(format))
+}
+
+// collectionSeparator returns the separator of the array values serialized
+// with format, csv being the default.
+func collectionSeparator(format string) string {
+	switch format {
+	case "ssv":
+		return " "
+	case "tsv":
+		return "\t"
+	case "pipes":
+		return "|"
+	}
+	return ","
+}
+
+// errRequestBodyTooLarge is returned for request bodies over the limit, which
+// aren't validated.
+const errRequestBodyTooLarge = notValidated("Request body too large, not validated")
+
+// readBody consumes up to limit bytes (0 means no limit) of the request body
+// and puts them back so it can still be forwarded to the target. Bigger
+// bodies are forwarded untouched, but aren't returned.
+func readBody(req *http.Request, limit int64) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	r := io.Reader(req.Body)
+	if limit > 0 {
+		r = io.LimitReader(req.Body, limit+1)
+	}
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		req.Body.Close()
+		return nil, err
+	}
+
+	if limit > 0 && int64(len(body)) > limit {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		return nil, errRequestBodyTooLarge
+	}
+
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func parseForm(ct string, body []byte) (url.Values, map[string][]*multipart.FileHeader, error) {
+	mediaType, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return url.Values{}, nil, nil
+	}
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		return form, nil, err
+	case "multipart/form-data":
+		r := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		form, err := r.ReadForm(32 << 20)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Only the file headers are needed, not the files spilled to disk
+		defer form.RemoveAll()
+		return url.Values(form.Value), form.File, nil
+	}
+	return url.Values{}, nil, nil
+}
+
+// operationParams merges the parameters defined at path level with those of
+// the operation, the latter taking precedence.
+func operationParams(root *spec.Swagger, item *spec.PathItem, op *spec.Operation) []spec.Parameter {
+	var params []spec.Parameter
+	index := make(map[string]int)
+
+	for _, list := range [][]spec.Parameter{item.Parameters, op.Parameters} {
+		for _, param := range list {
+			if param.Ref.String() != "" {
+				resolved, err := spec.ResolveParameter(root, param.Ref)
+				if err != nil {
+					continue
+				}
+				param = *resolved
+			}
+
+			key := param.In + "." + param.Name
+			if i, ok := index[key]; ok {
+				params[i] = param
+				continue
+			}
+			index[key] = len(params)
+			params = append(params, param)
+		}
+	}
+
+	return params
+}
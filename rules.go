@@ -0,0 +1,261 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-openapi/errors"
+)
+
+// Rule actions
+const (
+	RuleSuppress = "suppress"
+	RuleWarn     = "warn"
+)
+
+// Rule matches known violations, to suppress them or to downgrade them to
+// warnings. Empty fields match anything. Status is either a status code or
+// a status class such as 4XX, Pointer matches the violations under it.
+//
+//	rules:
+//	- operationId: getPetById
+//	  status: 200
+//	  pointer: /category
+//	  kind: required
+//	  action: warn
+//	  expires: 2017-12-31
+//	  reason: Categories are being migrated
+type Rule struct {
+	OperationID string     `json:"operationId,omitempty"`
+	Method      string     `json:"method,omitempty"`
+	Path        string     `json:"path,omitempty"`
+	Status      ruleStatus `json:"status,omitempty"`
+	Pointer     string     `json:"pointer,omitempty"`
+	Kind        string     `json:"kind,omitempty"`
+	Action      string     `json:"action,omitempty"`
+	Expires     string     `json:"expires,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+
+	expires time.Time
+}
+
+// ruleStatus accepts both numbers and strings.
+type ruleStatus string
+
+func (s *ruleStatus) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = ruleStatus(strings.ToUpper(fmt.Sprint(v)))
+	return nil
+}
+
+func (r *Rule) validate() error {
+	switch r.Action {
+	case "":
+		r.Action = RuleSuppress
+	case RuleSuppress, RuleWarn:
+	default:
+		return fmt.Errorf("unknown action %q", r.Action)
+	}
+
+	if s := string(r.Status); s != "" {
+		if _, err := strconv.Atoi(strings.TrimSuffix(s, "XX")); err != nil {
+			return fmt.Errorf("invalid status %q", s)
+		}
+	}
+
+	if r.Expires != "" {
+		// A date is valid through the whole day
+		if t, err := time.Parse("2006-01-02", r.Expires); err == nil {
+			r.expires = t.AddDate(0, 0, 1)
+		} else if t, err := time.Parse(time.RFC3339, r.Expires); err == nil {
+			r.expires = t
+		} else {
+			return fmt.Errorf("invalid expiry date %q", r.Expires)
+		}
+	}
+	return nil
+}
+
+// Expired reports whether the rule doesn't apply anymore at t.
+func (r *Rule) Expired(t time.Time) bool {
+	return !r.expires.IsZero() && !t.Before(r.expires)
+}
+
+func (r *Rule) matches(req *http.Request, ex *Exchange, v Violation) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, req.Method) {
+		return false
+	}
+
+	if r.OperationID != "" && (ex.Operation == nil || ex.Operation.ID != r.OperationID) {
+		return false
+	}
+
+	if r.Path != "" && r.Path != ex.Path {
+		return false
+	}
+
+	if s := string(r.Status); s != "" && s != strconv.Itoa(ex.Status) && s != fmt.Sprintf("%dXX", ex.Status/100) {
+		return false
+	}
+
+	if r.Pointer != "" && v.Pointer != r.Pointer && !strings.HasPrefix(v.Pointer, strings.TrimSuffix(r.Pointer, "/")+"/") {
+		return false
+	}
+
+	if r.Kind != "" && r.Kind != v.Kind {
+		return false
+	}
+	return true
+}
+
+// RuleSummary counts the violations a rule has matched.
+type RuleSummary struct {
+	Rule
+	Matched int `json:"matched"`
+}
+
+// Rules is a set of Rule applied to the violations before they are reported.
+type Rules struct {
+	mu      sync.Mutex
+	rules   []Rule
+	matched []int
+	now     func() time.Time
+}
+
+func NewRules(rules ...Rule) (*Rules, error) {
+	for i := range rules {
+		if err := rules[i].validate(); err != nil {
+			return nil, fmt.Errorf("rule %d: %s", i+1, err)
+		}
+	}
+	return &Rules{rules: rules, matched: make([]int, len(rules))}, nil
+}
+
+// LoadRules loads the rules from a YAML or JSON file.
+func LoadRules(path string) (*Rules, error) {
+	data, err := LoadJSONDocument(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	rules, err := NewRules(doc.Rules...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return rules, nil
+}
+
+// Expired returns the rules which don't apply anymore.
+func (rs *Rules) Expired() []Rule {
+	var expired []Rule
+	for _, r := range rs.rules {
+		if r.Expired(rs.clock()) {
+			expired = append(expired, r)
+		}
+	}
+	return expired
+}
+
+// Summary returns how many violations each rule has matched.
+func (rs *Rules) Summary() []RuleSummary {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	summary := make([]RuleSummary, len(rs.rules))
+	for i, r := range rs.rules {
+		summary[i] = RuleSummary{Rule: r, Matched: rs.matched[i]}
+	}
+	return summary
+}
+
+func (rs *Rules) clock() time.Time {
+	if rs.now != nil {
+		return rs.now()
+	}
+	return time.Now()
+}
+
+// apply splits err into the violations left to report, those downgraded to
+// warnings and the number of those suppressed.
+func (rs *Rules) apply(req *http.Request, err error) (kept, downgraded error, suppressed int) {
+	if rs == nil || err == nil {
+		return err, nil, 0
+	}
+
+	ex := ExchangeOf(req)
+	if ex == nil {
+		return err, nil, 0
+	}
+
+	var keep, warn []error
+	now := rs.clock()
+	errs := flattenErrors(err)
+	for _, e := range errs {
+		action := ""
+		for i := range rs.rules {
+			r := &rs.rules[i]
+			if r.Expired(now) || !r.matches(req, ex, Violations(e)[0]) {
+				continue
+			}
+
+			rs.mu.Lock()
+			rs.matched[i]++
+			rs.mu.Unlock()
+			action = r.Action
+			break
+		}
+
+		switch action {
+		case RuleSuppress:
+			suppressed++
+		case RuleWarn:
+			warn = append(warn, e)
+		default:
+			keep = append(keep, e)
+		}
+	}
+
+	if len(keep) == len(errs) {
+		return err, nil, 0
+	}
+	return compositeError(keep), compositeError(warn), suppressed
+}
+
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	cErr, ok := err.(*errors.CompositeError)
+	if !ok {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, err := range cErr.Errors {
+		errs = append(errs, flattenErrors(err)...)
+	}
+	return errs
+}
+
+func compositeError(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.CompositeValidationError(errs...)
+}
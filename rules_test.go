@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRules(t *testing.T) {
+	rules, err := LoadRules("./fixtures/rules.yml")
+	require.NoError(t, err)
+	rules.now = func() time.Time { return time.Date(2017, 5, 26, 0, 0, 0, 0, time.UTC) }
+	require.Len(t, rules.Expired(), 1)
+
+	reporter := &testReporter{}
+	app, err := New(openFixture(t, "petstore.json"), reporter, WithRules(rules))
+	require.NoError(t, err)
+
+	var body string
+	srv := httptest.NewServer(app.Handler(
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		}),
+	))
+	defer srv.Close()
+
+	body = `{"name": "doggie", "photoUrls": [], "category": {"name": 1}}`
+	http.Get(srv.URL + "/v2/pet/1")
+	assert.Empty(t, reporter.errors)
+	require.Len(t, reporter.warnings, 1)
+	assert.Contains(t, reporter.warnings[0], "Known violations")
+
+	// photoUrls is still required
+	body = `{"name": "doggie", "category": {"name": 1}}`
+	http.Get(srv.URL + "/v2/pet/1")
+	require.Len(t, reporter.errors, 1)
+	assert.Len(t, reporter.warnings, 1)
+	assert.Contains(t, reporter.errors[0].Error(), "photoUrls")
+	assert.NotContains(t, reporter.errors[0].Error(), "category")
+
+	// The missing status query parameter is suppressed
+	body = `[]`
+	http.Get(srv.URL + "/v2/pet/findByStatus")
+	assert.Empty(t, reporter.requestErrors)
+	assert.Len(t, reporter.success, 1)
+
+	// The inventory rule has expired
+	body = `{"sold": "many"}`
+	http.Get(srv.URL + "/v2/store/inventory")
+	assert.Len(t, reporter.errors, 2)
+
+	summary := rules.Summary()
+	assert.Equal(t, 2, summary[0].Matched)
+	assert.Equal(t, 1, summary[1].Matched)
+	assert.Equal(t, 0, summary[2].Matched)
+	assert.Equal(t, 1, app.Tally().Suppressed)
+}
+
+func TestRulesValidation(t *testing.T) {
+	_, err := NewRules(Rule{Action: "ignore"})
+	assert.Error(t, err)
+
+	_, err = NewRules(Rule{Status: "2xx"})
+	assert.Error(t, err, "status classes are uppercased when loaded")
+
+	_, err = NewRules(Rule{Expires: "tomorrow"})
+	assert.Error(t, err)
+
+	rules, err := NewRules(Rule{Expires: "2017-05-25"})
+	require.NoError(t, err)
+	rules.now = func() time.Time { return time.Date(2017, 5, 25, 23, 0, 0, 0, time.UTC) }
+	assert.Empty(t, rules.Expired(), "a date is valid through the whole day")
+}
+
+func TestRulesOutcomes(t *testing.T) {
+	target := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"sold": "many"}`))
+	})
+
+	t.Run("Enforce", func(t *testing.T) {
+		rules, err := NewRules(Rule{OperationID: "getInventory"})
+		require.NoError(t, err)
+
+		reporter := &testReporter{}
+		app, err := New(openFixture(t, "petstore.json"), reporter, WithRules(rules), WithEnforce(true))
+		require.NoError(t, err)
+		srv := httptest.NewServer(app.Handler(target))
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL + "/v2/store/inventory")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "suppressed violations don't replace the response")
+		assert.Equal(t, Tally{Success: 1, Suppressed: 1}, app.Tally())
+	})
+
+	t.Run("DowngradedRequest", func(t *testing.T) {
+		rules, err := NewRules(Rule{OperationID: "findPetsByStatus", Kind: "required", Action: RuleWarn})
+		require.NoError(t, err)
+
+		reporter := &testReporter{}
+		app, err := New(openFixture(t, "petstore.json"), reporter, WithRules(rules))
+		require.NoError(t, err)
+		srv := httptest.NewServer(app.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`[]`))
+		})))
+		defer srv.Close()
+
+		http.Get(srv.URL + "/v2/pet/findByStatus")
+		assert.Equal(t, Tally{Warnings: 1}, app.Tally(), "a single outcome")
+		require.Len(t, reporter.warnings, 1)
+		assert.Contains(t, reporter.warnings[0], "Known request violations")
+		assert.Empty(t, reporter.success)
+	})
+}
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/errors"
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/validate"
+)
+
+// sseEvent is a server-sent event. The response schema describes its data.
+type sseEvent struct {
+	Event string
+	ID    string
+	Data  string
+}
+
+func isEventStream(header http.Header) bool {
+	m, ok := parseMediaType(header.Get("Content-Type"))
+	return ok && m.tpe == "text" && m.subtype == "event-stream"
+}
+
+// sseParser parses an event stream as it is written, following
+// https://html.spec.whatwg.org/multipage/server-sent-events.html.
+type sseParser struct {
+	buf     []byte
+	event   sseEvent
+	data    []string
+	hasData bool
+}
+
+// feed returns the events completed by b.
+func (p *sseParser) feed(b []byte) []sseEvent {
+	p.buf = append(p.buf, b...)
+
+	var events []sseEvent
+	for {
+		i := bytes.IndexAny(p.buf, "\r\n")
+		if i == -1 {
+			break
+		}
+
+		line := string(p.buf[:i])
+		if p.buf[i] == '\r' {
+			// Wait to know whether it is a \r\n
+			if i+1 == len(p.buf) {
+				break
+			}
+			if p.buf[i+1] == '\n' {
+				i++
+			}
+		}
+		p.buf = p.buf[i+1:]
+
+		if ev, ok := p.line(line); ok {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+func (p *sseParser) line(line string) (sseEvent, bool) {
+	if line == "" {
+		ev, ok := p.event, p.hasData
+		ev.Data = strings.Join(p.data, "\n")
+		p.event, p.data, p.hasData = sseEvent{}, nil, false
+		return ev, ok
+	}
+
+	// Comments
+	if strings.HasPrefix(line, ":") {
+		return sseEvent{}, false
+	}
+
+	field, value := line, ""
+	if i := strings.IndexByte(line, ':'); i != -1 {
+		field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+	}
+
+	switch field {
+	case "data":
+		p.data = append(p.data, value)
+		p.hasData = true
+	case "event":
+		p.event.Event = value
+	case "id":
+		p.event.ID = value
+	}
+	return sseEvent{}, false
+}
+
+func parseEvents(body []byte) []sseEvent {
+	return (&sseParser{}).feed(body)
+}
+
+// Invalid events of a stream are reported in batches of at most
+// maxEventBatch events, and at most eventBatchInterval after the first one
+// when the next event comes.
+const (
+	maxEventBatch      = 10
+	eventBatchInterval = 10 * time.Second
+)
+
+// sseStream calls fn for every event written to a recorder, along with its
+// index in the stream.
+type sseStream struct {
+	sseParser
+	n  int
+	fn func(int, sseEvent)
+}
+
+func (s *sseStream) write(b []byte) {
+	for _, ev := range s.feed(b) {
+		s.fn(s.n, ev)
+		s.n++
+	}
+}
+
+// validateEvent validates the data of an event against the schema of the
+// response. Data which isn't JSON is validated as a string.
+func (st *state) validateEvent(op *spec.Operation, status, i int, ev sseEvent) error {
+	r, ok := operationResponse(op, status)
+	if !ok || r.Schema == nil {
+		return nil
+	}
+
+	var data interface{}
+	if err := json.Unmarshal([]byte(ev.Data), &data); err != nil {
+		data = ev.Data
+	}
+
+	v := validate.NewSchemaValidator(r.Schema, st.doc, fmt.Sprintf("event.%d", i), strfmt.Default)
+	if result := v.Validate(data); result.HasErrors() {
+		return result.AsError()
+	}
+	return nil
+}
+
+// validateEvents validates every event of a recorded event stream.
+func (st *state) validateEvents(op *spec.Operation, status int, body []byte) error {
+	var errs []error
+	for i, ev := range parseEvents(body) {
+		if err := st.validateEvent(op, status, i, ev); err != nil {
+			if cErr, ok := err.(*errors.CompositeError); ok {
+				errs = append(errs, cErr.Errors...)
+			} else {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.CompositeValidationError(errs...)
+}
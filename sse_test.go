@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ http.Flusher       = &WriterRecorder{}
+	_ http.Hijacker      = &WriterRecorder{}
+	_ http.CloseNotifier = &WriterRecorder{}
+	_ http.Flusher       = &BufferedRecorder{}
+	_ http.Hijacker      = &BufferedRecorder{}
+	_ http.CloseNotifier = &BufferedRecorder{}
+)
+
+func TestSSEParser(t *testing.T) {
+	p := &sseParser{}
+
+	assert.Empty(t, p.feed([]byte(": comment\nevent: upd")))
+	assert.Empty(t, p.feed([]byte("ate\r\ndata: {\"a\":\r")))
+	assert.Equal(t, []sseEvent{
+		{Event: "update", Data: "{\"a\":\n1}"},
+		{ID: "2", Data: "x"},
+	}, p.feed([]byte("\ndata: 1}\n\nid: 2\ndata:x\n\n\n")))
+
+	assert.Equal(t, []sseEvent{{Data: "y"}}, parseEvents([]byte("data: y\n\ndata: incomplete\n")))
+}
+
+func TestEventStream(t *testing.T) {
+	read := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"available\": 1}\n\n"))
+		w.(http.Flusher).Flush()
+
+		// The first event must reach the client before the stream ends
+		<-read
+		w.Write([]byte("data: {\"available\": \"none\"}\n\n"))
+		w.Write([]byte("data: []\n\n"))
+	}))
+	defer target.Close()
+
+	swagger := openFixture(t, "petstore.json")
+	swagger.Paths.Paths["/store/inventory"].Get.Produces = []string{"text/event-stream"}
+
+	for _, enforce := range []bool{false, true} {
+		reporter := &testReporter{}
+		var har bytes.Buffer
+		recorder := NewHARRecorder(&har)
+		app, err := New(swagger, reporter, WithTarget(target.URL), WithEnforce(enforce), WithHARRecorder(recorder))
+		require.NoError(t, err)
+
+		srv := httptest.NewServer(app.Router())
+
+		resp, err := http.Get(srv.URL + "/v2/store/inventory")
+		require.NoError(t, err)
+
+		r := bufio.NewReader(resp.Body)
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "data: {\"available\": 1}\n", line)
+		assert.Empty(t, reporter.errors, "the first event is valid")
+
+		read <- struct{}{}
+		_, err = r.ReadString(0)
+		resp.Body.Close()
+		srv.Close()
+
+		require.Len(t, reporter.errors, 1, "enforce: %v", enforce)
+		assert.Contains(t, reporter.errors[0].Error(), "event.1.available")
+		assert.Contains(t, reporter.errors[0].Error(), "event.2")
+		assert.Empty(t, reporter.success)
+
+		// A single outcome, once the status is known
+		assert.Equal(t, Tally{Errors: 1}, app.Tally())
+		require.Len(t, app.Violations(), 1)
+		assert.Equal(t, 200, app.Violations()[0].Status)
+
+		require.NoError(t, recorder.Close())
+		assert.Contains(t, har.String(), `"outcome":"error"`)
+	}
+
+	t.Run("Recorded", func(t *testing.T) {
+		app, err := New(swagger, nil)
+		require.NoError(t, err)
+
+		resp := &testResponse{status: 200, header: http.Header{}}
+		resp.Header().Set("Content-Type", "text/event-stream")
+		resp.body = []byte("data: {\"available\": 1}\n\n")
+		assert.NoError(t, app.Validate(resp, swagger.Paths.Paths["/store/inventory"].Get))
+
+		resp.body = []byte("data: {\"available\": 1}\n\ndata: []\n\n")
+		assert.Error(t, app.Validate(resp, swagger.Paths.Paths["/store/inventory"].Get))
+	})
+}
+
+func TestEventStreamBatches(t *testing.T) {
+	swagger := openFixture(t, "petstore.json")
+	swagger.Paths.Paths["/store/inventory"].Get.Produces = []string{"text/event-stream"}
+
+	reporter := &testReporter{}
+	app, err := New(swagger, reporter)
+	require.NoError(t, err)
+
+	end := make(chan struct{})
+	handler := app.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < maxEventBatch+2; i++ {
+			w.Write([]byte("data: []\n\n"))
+		}
+		w.(http.Flusher).Flush()
+		<-end
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest("GET", "/v2/store/inventory", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	// The first batch is reported while the stream goes on
+	for deadline := time.Now().Add(time.Second); app.Tally().Errors == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, Tally{Errors: 1}, app.Tally())
+
+	close(end)
+	<-done
+	assert.Equal(t, Tally{Errors: 2}, app.Tally())
+	require.Len(t, reporter.errors, 2)
+	assert.Contains(t, reporter.errors[0].Error(), "event.9")
+	assert.NotContains(t, reporter.errors[0].Error(), "event.10")
+	assert.Contains(t, reporter.errors[1].Error(), "event.11")
+	assert.Empty(t, reporter.success)
+}
+
+func TestHijack(t *testing.T) {
+	reporter := &testReporter{}
+	app, err := New(openFixture(t, "petstore.json"), reporter)
+	require.NoError(t, err)
+
+	handler := app.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+	}))
+
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer close(done)
+		handler.ServeHTTP(w, req)
+	}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	conn.Write([]byte("GET /v2/store/inventory HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n"))
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 101 Switching Protocols\r\n", status)
+
+	<-done
+	assert.Equal(t, []string{"Connection hijacked, not validated"}, reporter.warnings)
+	assert.Empty(t, reporter.errors)
+}
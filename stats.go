@@ -0,0 +1,246 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRecentViolations is the number of exchanges with violations kept by
+// the Proxy.
+const maxRecentViolations = 100
+
+// Tally counts the outcomes of the exchanges.
+type Tally struct {
+	Success       int `json:"success"`
+	Errors        int `json:"errors"`
+	RequestErrors int `json:"requestErrors"`
+	Warnings      int `json:"warnings"`
+	Suppressed    int `json:"suppressed"`
+}
+
+// ViolationRecord is an exchange which didn't conform to the spec.
+type ViolationRecord struct {
+	Time        time.Time   `json:"time"`
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	Path        string      `json:"path,omitempty"`
+	OperationID string      `json:"operationId,omitempty"`
+	Status      int         `json:"status,omitempty"`
+	Request     bool        `json:"request"`
+	Violations  []Violation `json:"violations"`
+}
+
+// stats accumulates the outcomes reported by the Proxy. Operations are keyed
+// by method and path so they survive spec reloads.
+type stats struct {
+	mu     sync.Mutex
+	total  Tally
+	ops    map[string]*Tally
+	recent []ViolationRecord
+}
+
+func newStats() *stats {
+	return &stats{ops: make(map[string]*Tally)}
+}
+
+func operationKey(method, path string) string {
+	return method + " " + path
+}
+
+func (s *stats) record(req *http.Request, fn func(*Tally)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(&s.total)
+	if ex := ExchangeOf(req); ex != nil && ex.Operation != nil {
+		key := operationKey(req.Method, ex.Path)
+		t, ok := s.ops[key]
+		if !ok {
+			t = &Tally{}
+			s.ops[key] = t
+		}
+		fn(t)
+	}
+}
+
+func (s *stats) violation(req *http.Request, request bool, err error) {
+	rec := ViolationRecord{
+		Time:       time.Now(),
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Request:    request,
+		Violations: Violations(err),
+	}
+	if ex := ExchangeOf(req); ex != nil {
+		rec.Path = ex.Path
+		rec.Status = ex.Status
+		if ex.Operation != nil {
+			rec.OperationID = ex.Operation.ID
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recent = append(s.recent, rec)
+	if len(s.recent) > maxRecentViolations {
+		s.recent = s.recent[len(s.recent)-maxRecentViolations:]
+	}
+}
+
+func (s *stats) tally() Tally {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total
+}
+
+func (s *stats) operation(method, path string) Tally {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.ops[operationKey(method, path)]; ok {
+		return *t
+	}
+	return Tally{}
+}
+
+func (s *stats) violations() []ViolationRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ViolationRecord{}, s.recent...)
+}
+
+func (s *stats) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.total = Tally{}
+	s.ops = make(map[string]*Tally)
+	s.recent = nil
+}
+
+func (proxy *Proxy) success(req *http.Request) {
+	if ex := ExchangeOf(req); ex != nil && len(ex.requestWarnings) > 0 {
+		proxy.warning(req, "")
+		return
+	}
+
+	proxy.stats.record(req, func(t *Tally) { t.Success++ })
+	proxy.metrics.outcome(req, "success")
+	proxy.record(req, &harValidation{Outcome: "success"})
+	proxy.reporter.Success(req)
+}
+
+// error reports err once the rules are applied, and returns the violations
+// left. An exchange has a single outcome: the violations downgraded by the
+// rules are only reported as a warning when no other violation is left, and
+// if every violation is suppressed the exchange is a success.
+func (proxy *Proxy) error(req *http.Request, err error) error {
+	err, downgraded, suppressed := proxy.Rules().apply(req, err)
+	if suppressed > 0 {
+		proxy.stats.record(req, func(t *Tally) { t.Suppressed += suppressed })
+	}
+
+	switch {
+	case err != nil:
+		proxy.reportError(req, err)
+	case downgraded != nil:
+		proxy.warning(req, "Known violations: "+downgraded.Error())
+	default:
+		proxy.success(req)
+	}
+	return err
+}
+
+func (proxy *Proxy) reportError(req *http.Request, err error) {
+	proxy.stats.record(req, func(t *Tally) { t.Errors++ })
+	proxy.stats.violation(req, false, err)
+	proxy.metrics.outcome(req, "error")
+	proxy.record(req, &harValidation{Outcome: "error", Errors: Violations(err)})
+	proxy.reporter.Error(req, err)
+}
+
+// requestError reports the request violations left once the rules are
+// applied. Those downgraded by the rules are kept on the Exchange, to be
+// reported with the outcome of the response.
+func (proxy *Proxy) requestError(req *http.Request, err error) {
+	err, downgraded, suppressed := proxy.Rules().apply(req, err)
+	if suppressed > 0 {
+		proxy.stats.record(req, func(t *Tally) { t.Suppressed += suppressed })
+	}
+
+	ex := ExchangeOf(req)
+	if downgraded != nil && ex != nil {
+		ex.requestWarnings = append(ex.requestWarnings, "Known request violations: "+downgraded.Error())
+	}
+	if err == nil {
+		return
+	}
+
+	proxy.stats.record(req, func(t *Tally) { t.RequestErrors++ })
+	proxy.stats.violation(req, true, err)
+	proxy.metrics.requestError(req)
+	if ex != nil {
+		ex.RequestErr = err
+	}
+	if r, ok := proxy.reporter.(RequestReporter); ok {
+		r.RequestError(req, err)
+	}
+}
+
+// requestWarning keeps msg on the Exchange, to be reported with the outcome
+// of the response.
+func (proxy *Proxy) requestWarning(req *http.Request, msg string) {
+	if ex := ExchangeOf(req); ex != nil {
+		ex.requestWarnings = append(ex.requestWarnings, msg)
+	}
+}
+
+// warning reports msg, along with the warnings of the request.
+func (proxy *Proxy) warning(req *http.Request, msg string) {
+	if ex := ExchangeOf(req); ex != nil && len(ex.requestWarnings) > 0 {
+		msgs := ex.requestWarnings
+		if msg != "" {
+			msgs = append([]string{msg}, msgs...)
+		}
+		msg = strings.Join(msgs, "\n")
+	}
+
+	proxy.stats.record(req, func(t *Tally) { t.Warnings++ })
+	proxy.metrics.outcome(req, "warning")
+	proxy.record(req, &harValidation{Outcome: "warning", Warning: msg})
+	proxy.reporter.Warning(req, msg)
+}
+
+// Tally returns the outcomes of the exchanges seen so far.
+func (proxy *Proxy) Tally() Tally {
+	return proxy.stats.tally()
+}
+
+// Coverage returns the number of operations exercised so far, out of the
+// operations of the spec.
+func (proxy *Proxy) Coverage() (covered, total int) {
+	st := proxy.current()
+	total = len(st.operations)
+	return total - len(st.pending.list()), total
+}
+
+// Violations returns the most recent exchanges which didn't conform to the
+// spec.
+func (proxy *Proxy) Violations() []ViolationRecord {
+	return proxy.stats.violations()
+}
+
+// Reset clears the counters and the recent violations, and marks every
+// operation as pending again.
+func (proxy *Proxy) Reset() {
+	proxy.mu.Lock()
+	st := *proxy.state
+	st.pending = newPendingOperations(st.operations)
+	proxy.state = &st
+	proxy.mu.Unlock()
+
+	proxy.stats.reset()
+}
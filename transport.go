@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Transport returns an http.RoundTripper validating the requests sent with
+// next, or http.DefaultTransport when nil, and their responses, just as
+// Handler does for a server, so any http.Client can be checked against the
+// spec:
+//
+//	client := &http.Client{Transport: proxy.Transport(nil)}
+//
+// A response is validated once its body has been read. Requests failing to
+// get a response are validated, and reported as a warning.
+func (proxy *Proxy) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{proxy: proxy, next: next}
+}
+
+type transport struct {
+	proxy *Proxy
+	next  http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out, in, err := cloneRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *http.Response
+	var rtErr error
+	send := func(w http.ResponseWriter, req *http.Request) {
+		if resp, rtErr = t.next.RoundTrip(out); rtErr != nil {
+			// Leave Handler before it validates a response, the exchange
+			// still needs an outcome
+			t.proxy.warning(req, fmt.Sprintf("No response, not validated: %s", rtErr))
+			panic(http.ErrAbortHandler)
+		}
+		defer resp.Body.Close()
+
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}
+
+	// The response goes through Handler while the caller reads it
+	pr, pw := io.Pipe()
+	w := &pipeWriter{header: make(http.Header), pw: pw, ready: make(chan struct{})}
+	go func() {
+		defer func() {
+			if r := recover(); r != nil && r != http.ErrAbortHandler {
+				panic(r)
+			}
+			w.WriteHeader(http.StatusOK)
+			pw.Close()
+		}()
+		t.proxy.Handler(http.HandlerFunc(send)).ServeHTTP(w, in)
+	}()
+
+	<-w.ready
+	if rtErr != nil {
+		return nil, rtErr
+	}
+
+	validated := *resp
+	validated.Header = w.sent
+	validated.Body = &pipeBody{PipeReader: pr, resp: resp}
+	if w.status != resp.StatusCode {
+		// Replaced by the enforce mode
+		validated.StatusCode = w.status
+		validated.Status = fmt.Sprintf("%d %s", w.status, http.StatusText(w.status))
+		validated.ContentLength = -1
+	}
+	return &validated, nil
+}
+
+// cloneRequest returns the request to send and the request to validate,
+// each with its own copy of the body.
+func cloneRequest(req *http.Request) (out, in *http.Request, err error) {
+	var body []byte
+	if req.Body != nil {
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	out, in = req.Clone(req.Context()), req.Clone(req.Context())
+	if req.Body != nil {
+		out.Body = ioutil.NopCloser(bytes.NewReader(body))
+		out.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+		in.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return out, in, nil
+}
+
+// pipeWriter is the ResponseWriter of a response read from a pipe. ready is
+// closed once the status and headers are sent.
+type pipeWriter struct {
+	header http.Header
+	sent   http.Header
+	status int
+	pw     *io.PipeWriter
+	ready  chan struct{}
+	once   sync.Once
+}
+
+func (w *pipeWriter) Header() http.Header { return w.header }
+
+func (w *pipeWriter) WriteHeader(status int) {
+	w.once.Do(func() {
+		w.status = status
+		w.sent = w.header.Clone()
+		close(w.ready)
+	})
+}
+
+func (w *pipeWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	// Once the caller closes the body, the rest is only read for validation
+	w.pw.Write(b)
+	return len(b), nil
+}
+
+// pipeBody is the body of a validated response. Closing an event stream
+// stops reading it altogether, as it might never end.
+type pipeBody struct {
+	*io.PipeReader
+	resp *http.Response
+}
+
+func (b *pipeBody) Close() error {
+	if isEventStream(b.resp.Header) {
+		b.resp.Body.Close()
+	}
+	return b.PipeReader.Close()
+}
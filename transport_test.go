@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	var body string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	defer target.Close()
+
+	newClient := func(opts ...ProxyOpt) (*http.Client, *Proxy, *testReporter) {
+		reporter := &testReporter{}
+		app, err := New(openFixture(t, "petstore.json"), reporter, opts...)
+		require.NoError(t, err)
+		return &http.Client{Transport: app.Transport(nil)}, app, reporter
+	}
+
+	get := func(client *http.Client, path string) (*http.Response, string) {
+		resp, err := client.Get(target.URL + path)
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp, string(data)
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		client, app, reporter := newClient()
+		body = `{"name": "doggie", "photoUrls": []}`
+
+		resp, data := get(client, "/v2/pet/1")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, body, data)
+		assert.Len(t, reporter.success, 1)
+		assert.Empty(t, reporter.errors)
+		assert.Equal(t, 1, app.Tally().Success)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		client, _, reporter := newClient()
+		body = `{"photoUrls": []}`
+
+		_, data := get(client, "/v2/pet/1")
+		assert.Equal(t, body, data)
+		require.Len(t, reporter.errors, 1)
+		assert.Contains(t, reporter.errors[0].Error(), "name")
+
+		body = `[]`
+		get(client, "/v2/pet/findByStatus")
+		require.Len(t, reporter.requestErrors, 1)
+		assert.Contains(t, reporter.requestErrors[0].Error(), "status")
+
+		get(client, "/v2/unknown")
+		assert.Equal(t, []string{"Route not defined on the Spec"}, reporter.warnings)
+	})
+
+	t.Run("RequestBody", func(t *testing.T) {
+		client, _, reporter := newClient()
+		body = `{"name": "doggie", "photoUrls": []}`
+
+		resp, err := client.Post(target.URL+"/v2/pet", "application/json", strings.NewReader(`{"photoUrls": []}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Len(t, reporter.requestErrors, 1)
+		assert.Contains(t, reporter.requestErrors[0].Error(), "name")
+	})
+
+	t.Run("Enforce", func(t *testing.T) {
+		client, _, _ := newClient(WithEnforce(true))
+		body = `{"photoUrls": []}`
+
+		resp, data := get(client, "/v2/pet/1")
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+		assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+		assert.Contains(t, data, "name")
+	})
+
+	t.Run("TransportError", func(t *testing.T) {
+		client, _, reporter := newClient()
+		_, err := client.Get("http://127.0.0.1:1/v2/pet/1")
+		assert.Error(t, err)
+		assert.Empty(t, reporter.success)
+		assert.Empty(t, reporter.errors)
+		require.Len(t, reporter.warnings, 1)
+		assert.Contains(t, reporter.warnings[0], "No response, not validated")
+	})
+
+	t.Run("TransportErrorReported", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		app, err := New(openFixture(t, "petstore.json"), NewJSONReporter(buf))
+		require.NoError(t, err)
+		client := &http.Client{Transport: app.Transport(nil)}
+
+		_, err = client.Get("http://127.0.0.1:1/v2/pet/findByStatus")
+		assert.Error(t, err)
+
+		// The request violations are reported along with the warning
+		assert.Contains(t, buf.String(), `"outcome":"warning"`)
+		assert.Contains(t, buf.String(), `"requestErrors":[`)
+	})
+}
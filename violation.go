@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"strings"
+
+	"github.com/go-openapi/errors"
+)
+
+// Violation is a single contract violation found while validating an
+// exchange.
+type Violation struct {
+	In      string `json:"in,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Pointer string `json:"pointer,omitempty"`
+	Kind    string `json:"kind,omitempty"`
+	Message string `json:"message"`
+}
+
+var violationKinds = map[int32]string{
+	errors.InvalidTypeCode:           "type",
+	errors.RequiredFailCode:          "required",
+	errors.TooLongFailCode:           "maxLength",
+	errors.TooShortFailCode:          "minLength",
+	errors.PatternFailCode:           "pattern",
+	errors.EnumFailCode:              "enum",
+	errors.MultipleOfFailCode:        "multipleOf",
+	errors.MaxFailCode:               "maximum",
+	errors.MinFailCode:               "minimum",
+	errors.UniqueFailCode:            "uniqueItems",
+	errors.MaxItemsFailCode:          "maxItems",
+	errors.MinItemsFailCode:          "minItems",
+	errors.NoAdditionalItemsCode:     "additionalItems",
+	errors.TooFewPropertiesCode:      "minProperties",
+	errors.TooManyPropertiesCode:     "maxProperties",
+	errors.UnallowedPropertyCode:     "additionalProperties",
+	errors.FailedAllPatternPropsCode: "patternProperties",
+}
+
+// Violations flattens err into the individual violations it holds.
+func Violations(err error) []Violation {
+	if err == nil {
+		return nil
+	}
+
+	if cErr, ok := err.(*errors.CompositeError); ok {
+		var vs []Violation
+		for _, err := range cErr.Errors {
+			vs = append(vs, Violations(err)...)
+		}
+		return vs
+	}
+
+	v := Violation{Message: err.Error()}
+	if vErr, ok := err.(*errors.Validation); ok {
+		v.In = vErr.In
+		v.Name = strings.Trim(vErr.Name, ".")
+		v.Kind = violationKinds[vErr.Code()]
+		if v.In == "body" && v.Name != "" {
+			v.Pointer = "/" + strings.Replace(v.Name, ".", "/", -1)
+		}
+	}
+	return []Violation{v}
+}
@@ -1,7 +1,9 @@
 package proxy
 
 import (
+	"bufio"
 	"bytes"
+	"net"
 	"net/http"
 )
 
@@ -11,10 +13,21 @@ type Response interface {
 	Body() []byte
 }
 
+// WriterRecorder records a response while sending it. Limit bounds the number
+// of body bytes recorded: 0 means no limit and a negative Limit records
+// nothing. Event streams aren't recorded.
+//
+// It implements http.Flusher, http.Hijacker and http.CloseNotifier on behalf
+// of the wrapped ResponseWriter.
 type WriterRecorder struct {
 	http.ResponseWriter
-	status int
-	body   bytes.Buffer
+	Limit int64
+
+	status    int
+	body      bytes.Buffer
+	truncated bool
+	hijacked  bool
+	events    *sseStream
 }
 
 func (w *WriterRecorder) WriteHeader(status int) {
@@ -23,10 +36,20 @@ func (w *WriterRecorder) WriteHeader(status int) {
 }
 
 func (w *WriterRecorder) Write(body []byte) (n int, err error) {
-	if n, err := w.body.Write(body); err != nil {
+	if w.events != nil && isEventStream(w.Header()) {
+		n, err := w.ResponseWriter.Write(body)
+		w.events.write(body[:n])
 		return n, err
 	}
 
+	if !w.truncated {
+		if w.truncated = exceeds(w.Limit, w.body.Len(), len(body)); w.truncated {
+			w.body = bytes.Buffer{}
+		} else if n, err := w.body.Write(body); err != nil {
+			return n, err
+		}
+	}
+
 	return w.ResponseWriter.Write(body)
 }
 
@@ -34,9 +57,164 @@ func (w *WriterRecorder) Body() []byte {
 	return w.body.Bytes()
 }
 
+// Truncated reports whether the body went over the Limit, in which case
+// it hasn't been recorded.
+func (w *WriterRecorder) Truncated() bool {
+	return w.truncated
+}
+
 func (w *WriterRecorder) Status() int {
 	if w.status == 0 {
 		return 200
 	}
 	return w.status
 }
+
+func (w *WriterRecorder) Flush() {
+	flush(w.ResponseWriter)
+}
+
+// Hijack hands the connection over, nothing is recorded afterwards.
+func (w *WriterRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := hijack(w.ResponseWriter)
+	w.hijacked = w.hijacked || err == nil
+	return conn, rw, err
+}
+
+// Hijacked reports whether the connection has been hijacked.
+func (w *WriterRecorder) Hijacked() bool {
+	return w.hijacked
+}
+
+func (w *WriterRecorder) CloseNotify() <-chan bool {
+	return closeNotify(w.ResponseWriter)
+}
+
+// BufferedRecorder records a response without sending it, so it can still
+// be replaced. Send writes the recorded response. Once the body goes over
+// Limit, or if the response is an event stream, what has been recorded is
+// sent and the rest of the response goes straight through.
+type BufferedRecorder struct {
+	http.ResponseWriter
+	Limit int64
+
+	status      int
+	body        bytes.Buffer
+	passthrough bool
+	truncated   bool
+	hijacked    bool
+	events      *sseStream
+}
+
+func (w *BufferedRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *BufferedRecorder) Write(body []byte) (int, error) {
+	sse := w.events != nil && isEventStream(w.Header())
+	if !w.passthrough && (sse || exceeds(w.Limit, w.body.Len(), len(body))) {
+		w.passthrough = true
+		w.truncated = !sse
+		if err := w.send(); err != nil {
+			return 0, err
+		}
+		w.body = bytes.Buffer{}
+	}
+
+	if !w.passthrough {
+		return w.body.Write(body)
+	}
+
+	n, err := w.ResponseWriter.Write(body)
+	if sse {
+		w.events.write(body[:n])
+	}
+	return n, err
+}
+
+// Truncated reports whether the body went over the Limit, in which case
+// the response has already been sent.
+func (w *BufferedRecorder) Truncated() bool {
+	return w.truncated
+}
+
+func (w *BufferedRecorder) Body() []byte {
+	return w.body.Bytes()
+}
+
+func (w *BufferedRecorder) Status() int {
+	if w.status == 0 {
+		return 200
+	}
+	return w.status
+}
+
+func (w *BufferedRecorder) Send() error {
+	if w.passthrough || w.hijacked {
+		return nil
+	}
+	return w.send()
+}
+
+func (w *BufferedRecorder) send() error {
+	w.ResponseWriter.WriteHeader(w.Status())
+	_, err := w.ResponseWriter.Write(w.body.Bytes())
+	return err
+}
+
+// Flush only reaches the client once the response goes straight through,
+// the buffered response must be kept until it is validated.
+func (w *BufferedRecorder) Flush() {
+	if w.passthrough {
+		flush(w.ResponseWriter)
+	}
+}
+
+// Hijack hands the connection over, nothing is recorded afterwards.
+func (w *BufferedRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := hijack(w.ResponseWriter)
+	w.hijacked = w.hijacked || err == nil
+	return conn, rw, err
+}
+
+// Hijacked reports whether the connection has been hijacked.
+func (w *BufferedRecorder) Hijacked() bool {
+	return w.hijacked
+}
+
+func (w *BufferedRecorder) CloseNotify() <-chan bool {
+	return closeNotify(w.ResponseWriter)
+}
+
+// exceeds reports whether writing n more bytes to a body of size bytes goes
+// over limit.
+func exceeds(limit int64, size, n int) bool {
+	if limit == 0 {
+		return false
+	}
+	return int64(size+n) > limit
+}
+
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func hijack(w http.ResponseWriter) (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return h.Hijack()
+}
+
+// closeNotify returns a channel which never fires when w can't notify.
+func closeNotify(w http.ResponseWriter) <-chan bool {
+	if cn, ok := w.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return make(chan bool)
+}
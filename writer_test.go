@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-openapi/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderLimit(t *testing.T) {
+	t.Run("WriterRecorder", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		wr := &WriterRecorder{ResponseWriter: w, Limit: 4}
+
+		wr.Write([]byte("abc"))
+		assert.False(t, wr.Truncated())
+		assert.Equal(t, "abc", string(wr.Body()))
+
+		wr.Write([]byte("def"))
+		assert.True(t, wr.Truncated())
+		assert.Empty(t, wr.Body())
+		assert.Equal(t, "abcdef", w.Body.String())
+	})
+
+	t.Run("BufferedRecorder", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		wr := &BufferedRecorder{ResponseWriter: w, Limit: 4}
+
+		wr.WriteHeader(201)
+		wr.Write([]byte("abc"))
+		assert.Empty(t, w.Body.String(), "nothing is sent before the limit")
+
+		wr.Write([]byte("def"))
+		assert.True(t, wr.Truncated())
+		assert.Equal(t, 201, w.Code)
+		assert.Equal(t, "abcdef", w.Body.String())
+
+		require.NoError(t, wr.Send())
+		assert.Equal(t, "abcdef", w.Body.String(), "Send doesn't write twice")
+	})
+
+	t.Run("Unlimited", func(t *testing.T) {
+		wr := &WriterRecorder{ResponseWriter: httptest.NewRecorder()}
+		wr.Write([]byte(strings.Repeat("a", 1024)))
+		assert.False(t, wr.Truncated())
+		assert.Len(t, wr.Body(), 1024)
+	})
+}
+
+func TestLargeBodies(t *testing.T) {
+	swagger := openFixture(t, "petstore.json")
+	swagger.Paths.Paths["/pet/{petId}"].Get.AddExtension(StreamExtension, true)
+
+	upload := swagger.Paths.Paths["/pet/{petId}/uploadImage"].Post
+	upload.Responses.StatusCodeResponses[200] = *spec.NewResponse().
+		WithSchema(&spec.Schema{SchemaProps: spec.SchemaProps{Type: spec.StringOrArray{"file"}}})
+
+	reporter := &testReporter{}
+	app, err := New(swagger, reporter, WithMaxBodySize(16))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(app.Handler(
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"a":"` + strings.Repeat("a", 32) + `"}`))
+		}),
+	))
+	defer srv.Close()
+
+	http.Get(srv.URL + "/v2/store/inventory")
+	assert.Equal(t, []string{"Body too large, not validated"}, reporter.warnings)
+
+	http.Get(srv.URL + "/v2/pet/1")
+	http.Post(srv.URL+"/v2/pet/1/uploadImage", "multipart/form-data; boundary=x", strings.NewReader("--x--\r\n"))
+	assert.Len(t, reporter.success, 2, "stream-only operations")
+	assert.Len(t, reporter.warnings, 1)
+	assert.Empty(t, reporter.errors)
+}
@@ -0,0 +1,284 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// xmlNode is an element of a parsed XML document.
+type xmlNode struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	children []*xmlNode
+	text     string
+}
+
+func parseXML(data []byte) (*xmlNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	var root *xmlNode
+	var stack []*xmlNode
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name, attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("XML document has no root element")
+	}
+	return root, nil
+}
+
+// named returns the children with the first of names found.
+func (n *xmlNode) named(names []string, namespace string) []*xmlNode {
+	for _, name := range names {
+		var nodes []*xmlNode
+		for _, c := range n.children {
+			if c.name.Local == name && (namespace == "" || c.name.Space == namespace) {
+				nodes = append(nodes, c)
+			}
+		}
+		if len(nodes) > 0 {
+			return nodes
+		}
+	}
+	return nil
+}
+
+func (n *xmlNode) child(names []string, namespace string) *xmlNode {
+	if nodes := n.named(names, namespace); len(nodes) > 0 {
+		return nodes[0]
+	}
+	return nil
+}
+
+func (n *xmlNode) attr(name, namespace string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.Name.Local == name && (namespace == "" || a.Name.Space == namespace) {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// xmlObject returns the xml object of a schema, looking at the referenced
+// schema when the schema itself has none.
+func xmlObject(schema, resolved *spec.Schema) spec.XMLObject {
+	if schema.XML != nil {
+		return *schema.XML
+	}
+	if resolved.XML != nil {
+		return *resolved.XML
+	}
+	return spec.XMLObject{}
+}
+
+// xmlNames returns the element names a property can take: the name of its
+// xml object, the property name, and the name of the referenced schema's
+// xml object, in that order.
+func xmlNames(schema, resolved *spec.Schema, property string) []string {
+	var names []string
+	add := func(name string) {
+		for _, n := range names {
+			if n == name {
+				return
+			}
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	if schema.XML != nil {
+		add(schema.XML.Name)
+	}
+	add(property)
+	if resolved.XML != nil {
+		add(resolved.XML.Name)
+	}
+	return names
+}
+
+// xmlRootName returns the name expected for the root element of a document
+// described by schema, or "" if there is no constraint.
+func (st *state) xmlRootName(schema *spec.Schema) string {
+	if x := xmlObject(schema, st.resolveSchema(schema)); x.Name != "" {
+		return x.Name
+	}
+	if ref := schema.Ref.String(); ref != "" {
+		return path.Base(ref)
+	}
+	return ""
+}
+
+// decodeXML decodes an XML document into the values described by schema,
+// honoring its xml objects, so that it can be validated against it.
+func (st *state) decodeXML(schema *spec.Schema, data []byte) (interface{}, error) {
+	root, err := parseXML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	x := xmlObject(schema, st.resolveSchema(schema))
+	if name := st.xmlRootName(schema); name != "" && name != root.name.Local {
+		return nil, fmt.Errorf("XML root element should be <%s>, but got <%s>", name, root.name.Local)
+	}
+	if x.Namespace != "" && x.Namespace != root.name.Space {
+		return nil, fmt.Errorf("XML root element should be in namespace %q, but got %q", x.Namespace, root.name.Space)
+	}
+
+	return st.xmlValue(schema, root), nil
+}
+
+func (st *state) xmlValue(schema *spec.Schema, n *xmlNode) interface{} {
+	schema = st.resolveSchema(schema)
+
+	switch schemaType(schema) {
+	case "object":
+		obj := make(map[string]interface{})
+		for name, prop := range st.xmlProperties(schema) {
+			prop := prop
+			resolved := st.resolveSchema(&prop)
+			x := xmlObject(&prop, resolved)
+			names := xmlNames(&prop, resolved, name)
+
+			if x.Attribute {
+				for _, elem := range names {
+					if value, ok := n.attr(elem, x.Namespace); ok {
+						obj[name] = xmlScalar(resolved, value)
+						break
+					}
+				}
+				continue
+			}
+
+			if schemaType(resolved) != "array" {
+				if c := n.child(names, x.Namespace); c != nil {
+					obj[name] = st.xmlValue(&prop, c)
+				}
+				continue
+			}
+
+			if x.Wrapped {
+				if wrapper := n.child(names, x.Namespace); wrapper != nil {
+					obj[name] = st.xmlItems(resolved, wrapper.children)
+				}
+				continue
+			}
+
+			if resolved.Items != nil && resolved.Items.Schema != nil {
+				items := resolved.Items.Schema
+				names = append(xmlNames(items, st.resolveSchema(items), ""), names...)
+			}
+			if nodes := n.named(names, x.Namespace); len(nodes) > 0 {
+				obj[name] = st.xmlItems(resolved, nodes)
+			}
+		}
+		return obj
+	case "array":
+		return st.xmlItems(schema, n.children)
+	}
+	return xmlScalar(schema, strings.TrimSpace(n.text))
+}
+
+func (st *state) xmlItems(schema *spec.Schema, nodes []*xmlNode) []interface{} {
+	items := make([]interface{}, len(nodes))
+	for i, n := range nodes {
+		if schema.Items != nil && schema.Items.Schema != nil {
+			items[i] = st.xmlValue(schema.Items.Schema, n)
+		} else {
+			items[i] = strings.TrimSpace(n.text)
+		}
+	}
+	return items
+}
+
+// xmlProperties returns the properties of an object schema, including those
+// of its allOf schemas.
+func (st *state) xmlProperties(schema *spec.Schema) map[string]spec.Schema {
+	props := make(map[string]spec.Schema)
+	for i := range schema.AllOf {
+		for name, prop := range st.xmlProperties(st.resolveSchema(&schema.AllOf[i])) {
+			props[name] = prop
+		}
+	}
+	for name, prop := range schema.Properties {
+		props[name] = prop
+	}
+	return props
+}
+
+// xmlScalar converts text to the type of schema. Text which can't be
+// converted is kept as is, so that the validator reports it.
+func xmlScalar(schema *spec.Schema, text string) interface{} {
+	switch schemaType(schema) {
+	case "integer":
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return i
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(text); err == nil {
+			return b
+		}
+	}
+	return text
+}
+
+// schemaType returns the non null type of schema, defaulting to object for
+// schemas with properties.
+func schemaType(schema *spec.Schema) string {
+	for _, t := range schema.Type {
+		if t != "null" {
+			return t
+		}
+	}
+	if len(schema.Properties) > 0 || len(schema.AllOf) > 0 {
+		return "object"
+	}
+	return ""
+}
+
+func isXML(mime string) bool {
+	m, ok := parseMediaType(mime)
+	if !ok {
+		return false
+	}
+	if m.suffix() == "xml" {
+		return true
+	}
+	return (m.tpe == "application" || m.tpe == "text") && m.subtype == "xml"
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-openapi/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXMLBody(t *testing.T) {
+	swagger := openFixture(t, "petstore.json")
+	app, err := New(swagger, nil)
+	require.NoError(t, err)
+
+	resp := &testResponse{status: 200, header: http.Header{}}
+	resp.Header().Set("Content-Type", "application/xml")
+
+	t.Run("Object", func(t *testing.T) {
+		op := swagger.Paths.Paths["/pet/{petId}"].Get
+
+		resp.body = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<Pet>
+  <id>1</id>
+  <category><id>1</id><name>Dogs</name></category>
+  <name>doggie</name>
+  <photoUrl><photoUrls>http://example.com/1.png</photoUrls></photoUrl>
+  <tag><Tag><id>1</id><name>good</name></Tag></tag>
+  <status>available</status>
+</Pet>`)
+		assert.NoError(t, app.ValidateBody(resp, op))
+
+		resp.body = []byte(`<Pet><id>one</id><name>doggie</name><photoUrl/></Pet>`)
+		assert.Error(t, app.ValidateBody(resp, op), "id is not an integer")
+
+		resp.body = []byte(`<Pet><id>1</id><photoUrl/></Pet>`)
+		assert.Error(t, app.ValidateBody(resp, op), "name is required")
+
+		resp.body = []byte(`<Pet><id>1</id><name>doggie</name><photoUrl/><status>lost</status></Pet>`)
+		assert.Error(t, app.ValidateBody(resp, op), "status is not in the enum")
+
+		resp.body = []byte(`<Dog><id>1</id><name>doggie</name><photoUrl/></Dog>`)
+		assert.Error(t, app.ValidateBody(resp, op), "root element should be Pet")
+
+		resp.body = []byte(`<Pet><id>1</id>`)
+		assert.Error(t, app.ValidateBody(resp, op), "malformed document")
+	})
+
+	t.Run("Array", func(t *testing.T) {
+		op := swagger.Paths.Paths["/pet/findByStatus"].Get
+
+		resp.body = []byte(`<pets><Pet><id>1</id><name>doggie</name><photoUrl/></Pet></pets>`)
+		assert.NoError(t, app.ValidateBody(resp, op))
+
+		resp.body = []byte(`<pets><Pet><id>1</id></Pet></pets>`)
+		assert.Error(t, app.ValidateBody(resp, op))
+	})
+
+	t.Run("Attributes", func(t *testing.T) {
+		schema := new(spec.Schema).
+			Typed("object", "").
+			WithXMLName("book").
+			WithXMLNamespace("http://example.com/schema").
+			SetProperty("id", *spec.Int64Property().WithXMLName("id").AsXMLAttribute()).
+			SetProperty("authors", *spec.ArrayProperty(spec.StringProperty().WithXMLName("author")))
+		schema.Required = []string{"id", "authors"}
+
+		op := &spec.Operation{}
+		op.RespondsWith(200, spec.NewResponse().WithSchema(schema))
+
+		resp.body = []byte(`<book xmlns="http://example.com/schema" id="1"><author>A</author><author>B</author></book>`)
+		assert.NoError(t, app.ValidateBody(resp, op))
+
+		resp.body = []byte(`<book xmlns="http://example.com/schema" id="x"><author>A</author></book>`)
+		assert.Error(t, app.ValidateBody(resp, op), "id is not an integer")
+
+		resp.body = []byte(`<book xmlns="http://example.com/schema" id="1"></book>`)
+		assert.Error(t, app.ValidateBody(resp, op), "authors are required")
+
+		resp.body = []byte(`<book id="1"><author>A</author></book>`)
+		assert.Error(t, app.ValidateBody(resp, op), "namespace is wrong")
+	})
+}